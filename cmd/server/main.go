@@ -6,6 +6,7 @@ import (
 	"os"
 	"strings"
 
+	"github.com/nexes/goIRC/pkg/format"
 	"github.com/nexes/goIRC/pkg/irc"
 )
 
@@ -35,7 +36,7 @@ func main() {
 
 	// this event is called when privmsg is received
 	client.HandleEventFunc(irc.EventMessage, func(event irc.EventType) {
-		fmt.Printf("[%s]: %s - %s\n", event.Room, event.Nick, event.Message)
+		fmt.Printf("[%s]: %s - %s\n", event.Room, event.Nick, format.ANSI(format.Parse(event.Message)))
 	})
 
 	client.HandleEventFunc(irc.EventChannelMessage, func(event irc.EventType) {
@@ -58,7 +59,13 @@ func main() {
 		case irc.RPL_ROOMPART:
 			fmt.Printf("\t%s Parted %s\n", event.Nick, event.Room)
 		case irc.RPL_ROOMQUIT:
-			fmt.Printf("\t%s Quit %s\n", event.Nick, event.Room)
+			fmt.Printf("\t%s Quit %s (%s)\n", event.Nick, strings.Join(event.Rooms, ","), event.Message)
+		case irc.RPL_ROOMKICK:
+			fmt.Printf("\t%s was kicked from %s by %s (%s)\n", event.Target, event.Room, event.Nick, event.Message)
+		case irc.RPL_NICKCHANGE:
+			fmt.Printf("\t%s is now known as %s\n", event.Nick, event.Target)
+		case irc.RPL_ROOMTOPIC:
+			fmt.Printf("\t%s changed the topic of %s to: %s\n", event.Nick, event.Room, event.Message)
 		case irc.RPL_TOPIC:
 			fmt.Printf("TOPIC: %s\n\n", event.Message)
 		}
@@ -77,36 +84,22 @@ func main() {
 			args := strings.Split(strings.TrimSpace(input), " ")
 
 			switch strings.ToLower(args[0]) {
-			case "/join":
-				client.Command(irc.Command{
-					Action: "join",
-					Args:   args[1:],
-				})
-			case "/list":
-				client.Command(irc.Command{
-					Action: "list",
-					Args:   args[1:],
-				})
-			case "/names":
-				client.Command(irc.Command{
-					Action: "names",
-					Args:   args[1:],
-				})
-			case "/part":
-				client.Command(irc.Command{
-					Action: "part",
-					Args:   args[1:],
-				})
-			case "/kick":
-				client.Command(irc.Command{
-					Action: "kick",
-					Args:   args[1:],
-				})
 			case "/quit":
 				client.StopConnection()
 				break loop
 
 			default:
+				if strings.HasPrefix(args[0], "/") {
+					err := client.Command(irc.Command{
+						Action: args[0][1:],
+						Args:   args[1:],
+					})
+					if err != nil {
+						fmt.Println(err)
+					}
+					continue
+				}
+
 				message := strings.Join(args, " ")
 				client.WriteToTarget(currentRoom, message)
 			}
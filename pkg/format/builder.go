@@ -0,0 +1,161 @@
+package format
+
+import (
+	"fmt"
+	"strings"
+)
+
+//Builder builds formatted text to send. Each styled piece of text turns its formatting off again at
+//the end, so pieces can be added in any order
+type Builder struct {
+	text strings.Builder
+}
+
+//Text adds text without formatting
+func (b *Builder) Text(text string) *Builder {
+	b.text.WriteString(text)
+	return b
+}
+
+//Bold adds bold text
+func (b *Builder) Bold(text string) *Builder {
+	return b.wrap(Bold, text)
+}
+
+//Italic adds italic text
+func (b *Builder) Italic(text string) *Builder {
+	return b.wrap(Italic, text)
+}
+
+//Underline adds underlined text
+func (b *Builder) Underline(text string) *Builder {
+	return b.wrap(Underline, text)
+}
+
+//Strikethrough adds struck through text
+func (b *Builder) Strikethrough(text string) *Builder {
+	return b.wrap(Strikethrough, text)
+}
+
+//Monospace adds monospace text
+func (b *Builder) Monospace(text string) *Builder {
+	return b.wrap(Monospace, text)
+}
+
+//Reverse adds text with the foreground and background colors swapped
+func (b *Builder) Reverse(text string) *Builder {
+	return b.wrap(Reverse, text)
+}
+
+//Color adds text in one of the mIRC colors, 0-98. A background below 0 keeps the current one, any other
+//number outside of the palette is the default color
+func (b *Builder) Color(foreground, background int, text string) *Builder {
+	// always two digits, a digit at the start of the text would otherwise be read as part of the color
+	code := fmt.Sprintf("%c%02d", ColorCode, colorIndex(foreground))
+	if background >= 0 {
+		code += fmt.Sprintf(",%02d", colorIndex(background))
+	}
+
+	// a bare \x03 would take digits at the start of the next text as a color
+	b.text.WriteString(code + text + string(Reset))
+	return b
+}
+
+//HexColor adds text in a 0xRRGGBB color, a background above 0xFFFFFF keeps the current one
+func (b *Builder) HexColor(foreground, background uint32, text string) *Builder {
+	code := fmt.Sprintf("%c%06X", HexColorCode, foreground&0xffffff)
+	if background <= 0xffffff {
+		code += fmt.Sprintf(",%06X", background)
+	}
+
+	b.text.WriteString(code + text + string(Reset))
+	return b
+}
+
+//Span adds text with the style
+func (b *Builder) Span(style Style, text string) *Builder {
+	var codes strings.Builder
+
+	for _, toggle := range []struct {
+		on   bool
+		code byte
+	}{
+		{style.Bold, Bold}, {style.Italic, Italic}, {style.Underline, Underline},
+		{style.Strikethrough, Strikethrough}, {style.Monospace, Monospace}, {style.Reverse, Reverse},
+	} {
+		if toggle.on {
+			codes.WriteByte(toggle.code)
+		}
+	}
+
+	// \x04 always sets the foreground, so it is only used when there is one
+	foreground, background := style.Foreground, style.Background
+	if foreground.Set && (foreground.RGB || (background.Set && background.RGB)) {
+		codes.WriteString(fmt.Sprintf("%c%06X", HexColorCode, foreground.Value()))
+		if background.Set {
+			codes.WriteString(fmt.Sprintf(",%06X", background.Value()))
+		}
+	} else if foreground.Set || background.Set {
+		// 99 keeps the default foreground when only the background is set
+		code := fmt.Sprintf("%c%02d", ColorCode, defaultColor)
+		if foreground.Set {
+			code = fmt.Sprintf("%c%02d", ColorCode, colorIndex(foreground.Index))
+		}
+
+		if background.Set && background.RGB {
+			code += fmt.Sprintf(",%02d", nearestColor(background.Hex))
+		} else if background.Set {
+			code += fmt.Sprintf(",%02d", colorIndex(background.Index))
+		}
+
+		codes.WriteString(code)
+	}
+
+	b.text.WriteString(codes.String())
+	b.text.WriteString(text)
+	if codes.Len() > 0 {
+		b.text.WriteByte(Reset)
+	}
+
+	return b
+}
+
+//String the formatted text
+func (b *Builder) String() string {
+	return b.text.String()
+}
+
+//the mIRC color number, numbers outside of the palette are the default color
+func colorIndex(index int) int {
+	if index < 0 || index >= len(palette) {
+		return defaultColor
+	}
+
+	return index
+}
+
+//the palette color closest to the 0xRRGGBB color
+func nearestColor(hex uint32) int {
+	nearest, best := 0, -1
+	for index, color := range palette {
+		distance := 0
+		for shift := 0; shift <= 16; shift += 8 {
+			difference := int(hex>>shift&0xff) - int(color>>shift&0xff)
+			distance += difference * difference
+		}
+
+		if best < 0 || distance < best {
+			nearest, best = index, distance
+		}
+	}
+
+	return nearest
+}
+
+func (b *Builder) wrap(code byte, text string) *Builder {
+	b.text.WriteByte(code)
+	b.text.WriteString(text)
+	b.text.WriteByte(code)
+
+	return b
+}
@@ -0,0 +1,88 @@
+package format
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuilder(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(b *Builder)
+		want  string
+	}{
+		{"styles", func(b *Builder) { b.Text("a ").Bold("b").Italic("i").Underline("u") }, "a \x02b\x02\x1Di\x1D\x1Fu\x1F"},
+		{"color", func(b *Builder) { b.Color(Red, -1, "1") }, "\x03041\x0F"},
+		{"color and background", func(b *Builder) { b.Color(Red, Blue, "x") }, "\x0304,02x\x0F"},
+		{"color out of range", func(b *Builder) { b.Color(100, -5, "x").Color(-1, 250, "y") }, "\x0399x\x0F\x0399,99y\x0F"},
+		{"hex color", func(b *Builder) { b.HexColor(0xff8000, 0x1000000, "x") }, "\x04FF8000x\x0F"},
+		{"hex color and background", func(b *Builder) { b.HexColor(0xff8000, 0x000080, "x") }, "\x04FF8000,000080x\x0F"},
+		{"plain span", func(b *Builder) { b.Span(Style{}, "x") }, "x"},
+		{"span", func(b *Builder) {
+			b.Span(Style{Bold: true, Foreground: Color{Set: true, Index: Red}}, "x")
+		}, "\x02\x0304x\x0F"},
+		{"span background only", func(b *Builder) {
+			b.Span(Style{Background: Color{Set: true, Index: Blue}}, "x")
+		}, "\x0399,02x\x0F"},
+		{"span hex background only", func(b *Builder) {
+			b.Span(Style{Background: Color{Set: true, RGB: true, Hex: 0xfe0101}}, "x")
+		}, "\x0399,04x\x0F"},
+		{"span hex foreground", func(b *Builder) {
+			b.Span(Style{Foreground: Color{Set: true, RGB: true, Hex: 0x123456}, Background: Color{Set: true, Index: Black}}, "x")
+		}, "\x04123456,000000x\x0F"},
+	}
+
+	for _, test := range tests {
+		var b Builder
+		test.build(&b)
+		if got := b.String(); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+//what the builder writes parses back to the same style
+func TestBuilderParse(t *testing.T) {
+	styles := []Style{
+		{Bold: true, Underline: true},
+		{Foreground: Color{Set: true, Index: 52}, Background: Color{Set: true, Index: White}},
+		{Background: Color{Set: true, Index: Cyan}},
+		{Reverse: true, Foreground: Color{Set: true, RGB: true, Hex: 0xabcdef}},
+	}
+
+	for _, style := range styles {
+		var b Builder
+		spans := Parse(b.Span(style, "text").String())
+		if len(spans) != 1 || spans[0].Style != style || spans[0].Text != "text" {
+			t.Errorf("%+v parsed back as %+v", style, spans)
+		}
+	}
+}
+
+//text after a colored piece keeps its leading digits
+func TestBuilderColorThenDigits(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func(b *Builder)
+		want  []Span
+	}{
+		{"color", func(b *Builder) { b.Color(Red, -1, "red").Text("5 apples") }, []Span{
+			{Style{Foreground: Color{Set: true, Index: Red}}, "red"}, {Text: "5 apples"},
+		}},
+		{"color and background", func(b *Builder) { b.Color(Red, Blue, "red").Text(",12") }, []Span{
+			{Style{Foreground: Color{Set: true, Index: Red}, Background: Color{Set: true, Index: Blue}}, "red"}, {Text: ",12"},
+		}},
+		{"hex color", func(b *Builder) { b.HexColor(0xff8000, 0x1000000, "orange").Text("cafe00") }, []Span{
+			{Style{Foreground: Color{Set: true, RGB: true, Hex: 0xff8000}}, "orange"}, {Text: "cafe00"},
+		}},
+	}
+
+	for _, test := range tests {
+		var b Builder
+		test.build(&b)
+
+		if got := Parse(b.String()); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %q parsed as %+v, want %+v", test.name, b.String(), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,280 @@
+//Package format parses the mIRC formatting codes used in irc messages into styled spans, strips them and
+//renders them for terminals, HTML and Markdown
+package format
+
+import (
+	"strings"
+)
+
+//the formatting control codes
+const (
+	Bold          = '\x02'
+	ColorCode     = '\x03'
+	HexColorCode  = '\x04'
+	Reset         = '\x0F'
+	Monospace     = '\x11'
+	Reverse       = '\x16'
+	Italic        = '\x1D'
+	Strikethrough = '\x1E'
+	Underline     = '\x1F'
+)
+
+//the 16 standard mIRC colors, \x03 also accepts 16-98 for the extended colors and 99 for the default
+const (
+	White = iota
+	Black
+	Blue
+	Green
+	Red
+	Brown
+	Magenta
+	Orange
+	Yellow
+	LightGreen
+	Cyan
+	LightCyan
+	LightBlue
+	Pink
+	Grey
+	LightGrey
+)
+
+//Color a mIRC color number or a hex color. The zero value is the default color of the client
+type Color struct {
+	Set bool
+	//Index is the mIRC color number, 0-98
+	Index int
+	//RGB is true for colors from \x04, Hex holds the color as 0xRRGGBB
+	RGB bool
+	Hex uint32
+}
+
+//Value the color as 0xRRGGBB
+func (c Color) Value() uint32 {
+	if c.RGB {
+		return c.Hex
+	}
+	if c.Index >= 0 && c.Index < len(palette) {
+		return palette[c.Index]
+	}
+
+	return 0
+}
+
+//the RGB values of the 99 mIRC colors
+var palette = [99]uint32{
+	0xffffff, 0x000000, 0x00007f, 0x009300, 0xff0000, 0x7f0000, 0x9c009c, 0xfc7f00,
+	0xffff00, 0x00fc00, 0x009393, 0x00ffff, 0x0000fc, 0xff00ff, 0x7f7f7f, 0xd2d2d2,
+	0x470000, 0x472100, 0x474700, 0x324700, 0x004700, 0x00472c, 0x004747, 0x002747,
+	0x000047, 0x2e0047, 0x470047, 0x47002a, 0x740000, 0x743a00, 0x747400, 0x517400,
+	0x007400, 0x007449, 0x007474, 0x004074, 0x000074, 0x4b0074, 0x740074, 0x740045,
+	0xb50000, 0xb56300, 0xb5b500, 0x7db500, 0x00b500, 0x00b571, 0x00b5b5, 0x0063b5,
+	0x0000b5, 0x7500b5, 0xb500b5, 0xb5006b, 0xff0000, 0xff8c00, 0xffff00, 0xb2ff00,
+	0x00ff00, 0x00ffa0, 0x00ffff, 0x008cff, 0x0000ff, 0xa500ff, 0xff00ff, 0xff0098,
+	0xff5959, 0xffb459, 0xffff71, 0xcfff60, 0x6fff6f, 0x65ffc9, 0x6dffff, 0x59b4ff,
+	0x5959ff, 0xc459ff, 0xff66ff, 0xff59bc, 0xff9c9c, 0xffd39c, 0xffff9c, 0xe2ff9c,
+	0x9cff9c, 0x9cffdb, 0x9cffff, 0x9cd3ff, 0x9c9cff, 0xdc9cff, 0xff9cff, 0xff94d3,
+	0x000000, 0x131313, 0x282828, 0x363636, 0x4d4d4d, 0x656565, 0x818181, 0x9f9f9f,
+	0xbcbcbc, 0xe2e2e2, 0xffffff,
+}
+
+//Style the formatting in effect for a span of text
+type Style struct {
+	Bold          bool
+	Italic        bool
+	Underline     bool
+	Strikethrough bool
+	Monospace     bool
+	Reverse       bool
+	Foreground    Color
+	Background    Color
+}
+
+//Plain returns true if the style has no formatting at all
+func (s Style) Plain() bool {
+	return s == Style{}
+}
+
+//Span text that has the same style throughout
+type Span struct {
+	Style Style
+	Text  string
+}
+
+//Parse splits the text into spans at every change of style, the formatting codes are removed from the text
+func Parse(text string) []Span {
+	var spans []Span
+	var style Style
+	var current strings.Builder
+
+	flush := func() {
+		if current.Len() == 0 {
+			return
+		}
+
+		if last := len(spans) - 1; last >= 0 && spans[last].Style == style {
+			spans[last].Text += current.String()
+		} else {
+			spans = append(spans, Span{Style: style, Text: current.String()})
+		}
+		current.Reset()
+	}
+
+	for index := 0; index < len(text); index++ {
+		switch text[index] {
+		case Bold:
+			flush()
+			style.Bold = !style.Bold
+		case Italic:
+			flush()
+			style.Italic = !style.Italic
+		case Underline:
+			flush()
+			style.Underline = !style.Underline
+		case Strikethrough:
+			flush()
+			style.Strikethrough = !style.Strikethrough
+		case Monospace:
+			flush()
+			style.Monospace = !style.Monospace
+		case Reverse:
+			flush()
+			style.Reverse = !style.Reverse
+		case Reset:
+			flush()
+			style = Style{}
+
+		case ColorCode:
+			flush()
+			fg, bg, length := parseColor(text[index+1:])
+			style.Foreground, style.Background = applyColors(style, fg, bg, length == 0)
+			index += length
+
+		case HexColorCode:
+			flush()
+			fg, bg, length := parseHexColor(text[index+1:])
+			style.Foreground, style.Background = applyColors(style, fg, bg, length == 0)
+			index += length
+
+		default:
+			current.WriteByte(text[index])
+		}
+	}
+	flush()
+
+	return spans
+}
+
+//Strip removes every formatting code from the text
+func Strip(text string) string {
+	if !strings.ContainsAny(text, "\x02\x03\x04\x0F\x11\x16\x1D\x1E\x1F") {
+		return text
+	}
+
+	var stripped strings.Builder
+	for _, span := range Parse(text) {
+		stripped.WriteString(span.Text)
+	}
+
+	return stripped.String()
+}
+
+//a color code without a color resets both colors, otherwise the background only changes if it was given
+func applyColors(style Style, fg, bg *Color, reset bool) (Color, Color) {
+	if reset {
+		return Color{}, Color{}
+	}
+
+	foreground, background := style.Foreground, style.Background
+	if fg != nil {
+		foreground = *fg
+	}
+	if bg != nil {
+		background = *bg
+	}
+
+	return foreground, background
+}
+
+//parse the NN[,MM] after \x03, length is the number of bytes used
+func parseColor(text string) (fg, bg *Color, length int) {
+	number, digits := colorNumber(text)
+	if digits == 0 {
+		return nil, nil, 0
+	}
+	fg = mircColor(number)
+	length = digits
+
+	if len(text) > length+1 && text[length] == ',' {
+		if number, digits := colorNumber(text[length+1:]); digits > 0 {
+			bg = mircColor(number)
+			length += 1 + digits
+		}
+	}
+
+	return fg, bg, length
+}
+
+//parse the RRGGBB[,RRGGBB] after \x04
+func parseHexColor(text string) (fg, bg *Color, length int) {
+	value, ok := hexColor(text)
+	if !ok {
+		return nil, nil, 0
+	}
+	fg = &Color{Set: true, RGB: true, Hex: value}
+	length = 6
+
+	if len(text) > 7 && text[6] == ',' {
+		if value, ok := hexColor(text[7:]); ok {
+			bg = &Color{Set: true, RGB: true, Hex: value}
+			length += 7
+		}
+	}
+
+	return fg, bg, length
+}
+
+//up to two digits
+func colorNumber(text string) (int, int) {
+	number, digits := 0, 0
+	for digits < 2 && digits < len(text) && text[digits] >= '0' && text[digits] <= '9' {
+		number = number*10 + int(text[digits]-'0')
+		digits++
+	}
+
+	return number, digits
+}
+
+//defaultColor the mIRC color number of the client's default color
+const defaultColor = 99
+
+//99 and anything past the palette is the default color
+func mircColor(number int) *Color {
+	if number >= len(palette) {
+		return &Color{}
+	}
+
+	return &Color{Set: true, Index: number}
+}
+
+func hexColor(text string) (uint32, bool) {
+	if len(text) < 6 {
+		return 0, false
+	}
+
+	var value uint32
+	for _, char := range []byte(text[:6]) {
+		switch {
+		case char >= '0' && char <= '9':
+			value = value<<4 | uint32(char-'0')
+		case char >= 'a' && char <= 'f':
+			value = value<<4 | uint32(char-'a'+10)
+		case char >= 'A' && char <= 'F':
+			value = value<<4 | uint32(char-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+
+	return value, true
+}
@@ -0,0 +1,125 @@
+package format
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	red := Color{Set: true, Index: Red}
+	blue := Color{Set: true, Index: Blue}
+
+	tests := []struct {
+		text string
+		want []Span
+	}{
+		{"plain", []Span{{Text: "plain"}}},
+		{"\x02bold\x02 plain", []Span{{Style{Bold: true}, "bold"}, {Text: " plain"}}},
+		{"\x1D\x1Fboth\x0F", []Span{{Style{Italic: true, Underline: true}, "both"}}},
+		{"\x0304red", []Span{{Style{Foreground: red}, "red"}}},
+		{"\x034,2red on blue", []Span{{Style{Foreground: red, Background: blue}, "red on blue"}}},
+		// the background stays when only the foreground changes
+		{"\x0304,02a\x0302b", []Span{{Style{Foreground: red, Background: blue}, "a"}, {Style{Foreground: blue, Background: blue}, "b"}}},
+		// a code without a color resets both
+		{"\x0304,02a\x03b", []Span{{Style{Foreground: red, Background: blue}, "a"}, {Text: "b"}}},
+		// only two digits belong to the color
+		{"\x03041", []Span{{Style{Foreground: red}, "1"}}},
+		{"\x0399default", []Span{{Text: "default"}}},
+		// a comma without a background is text
+		{"\x034,x", []Span{{Style{Foreground: red}, ",x"}}},
+		{"\x04FF8000,000080hex", []Span{{Style{
+			Foreground: Color{Set: true, RGB: true, Hex: 0xff8000},
+			Background: Color{Set: true, RGB: true, Hex: 0x000080},
+		}, "hex"}}},
+		{"\x04zz", []Span{{Text: "zz"}}},
+		// toggling a style off and on again joins the text
+		{"\x02a\x02\x02b\x02", []Span{{Style{Bold: true}, "ab"}}},
+		{"", nil},
+	}
+
+	for _, test := range tests {
+		if got := Parse(test.text); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Parse(%q)\n got %+v\nwant %+v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestStrip(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{"\x02bold\x02 \x1Ditalic\x1D", "bold italic"},
+		{"\x0304,12colored\x03 \x0311", "colored "},
+		{"\x04FFFFFFhex\x04", "hex"},
+		{"\x11\x16\x1E\x1F\x0F", ""},
+	}
+
+	for _, test := range tests {
+		if got := Strip(test.text); got != test.want {
+			t.Errorf("Strip(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestANSI(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain", "plain"},
+		{"\x02bold\x02 plain", "\x1b[0;1mbold\x1b[0m plain"},
+		{"\x1D\x1F\x16\x1Eall", "\x1b[0;3;4;7;9mall\x1b[0m"},
+		{"\x0304,01red", "\x1b[0;38;2;255;0;0;48;2;0;0;0mred\x1b[0m"},
+		{"\x04102030hex", "\x1b[0;38;2;16;32;48mhex\x1b[0m"},
+	}
+
+	for _, test := range tests {
+		if got := ANSI(Parse(test.text)); got != test.want {
+			t.Errorf("ANSI(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"a < b", "a &lt; b"},
+		{"\x02bold\x02", `<span style="font-weight:bold">bold</span>`},
+		{"\x1F\x1Eboth", `<span style="text-decoration:underline line-through">both</span>`},
+		{"\x11code", `<span style="font-family:monospace">code</span>`},
+		{"\x0304,01red", `<span style="color:#ff0000;background-color:#000000">red</span>`},
+		{"\x16reverse", `<span style="color:#ffffff;background-color:#000000">reverse</span>`},
+		{"\x0304\x16swapped", `<span style="background-color:#ff0000">swapped</span>`},
+	}
+
+	for _, test := range tests {
+		if got := HTML(Parse(test.text)); got != test.want {
+			t.Errorf("HTML(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"plain *text*", `plain \*text\*`},
+		{"\x02 bold \x02x", " **bold** x"},
+		{"\x02\x1D\x1Eall\x0F", "**_~~all~~_**"},
+		{"\x11a*b\x11", "`a*b`"},
+		{"\x11a`b\x11", "`` a`b ``"},
+		// colors and underline have no Markdown
+		{"\x0304\x1Fred", "red"},
+	}
+
+	for _, test := range tests {
+		if got := Markdown(Parse(test.text)); got != test.want {
+			t.Errorf("Markdown(%q) = %q, want %q", test.text, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package format
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+//ANSI renders the spans with ANSI escape codes for terminals that support 24 bit color
+func ANSI(spans []Span) string {
+	var out strings.Builder
+	styled := false
+
+	for _, span := range spans {
+		if span.Style.Plain() {
+			if styled {
+				out.WriteString("\x1b[0m")
+				styled = false
+			}
+			out.WriteString(span.Text)
+			continue
+		}
+
+		codes := []string{"0"}
+		if span.Style.Bold {
+			codes = append(codes, "1")
+		}
+		if span.Style.Italic {
+			codes = append(codes, "3")
+		}
+		if span.Style.Underline {
+			codes = append(codes, "4")
+		}
+		if span.Style.Reverse {
+			codes = append(codes, "7")
+		}
+		if span.Style.Strikethrough {
+			codes = append(codes, "9")
+		}
+		if span.Style.Foreground.Set {
+			codes = append(codes, ansiColor(38, span.Style.Foreground))
+		}
+		if span.Style.Background.Set {
+			codes = append(codes, ansiColor(48, span.Style.Background))
+		}
+
+		out.WriteString("\x1b[" + strings.Join(codes, ";") + "m")
+		out.WriteString(span.Text)
+		styled = true
+	}
+
+	if styled {
+		out.WriteString("\x1b[0m")
+	}
+
+	return out.String()
+}
+
+func ansiColor(code int, color Color) string {
+	value := color.Value()
+	return fmt.Sprintf("%d;2;%d;%d;%d", code, value>>16, value>>8&0xff, value&0xff)
+}
+
+//HTML renders the spans as escaped HTML with a styled <span> for each formatted span
+func HTML(spans []Span) string {
+	var out strings.Builder
+
+	for _, span := range spans {
+		text := html.EscapeString(span.Text)
+		if span.Style.Plain() {
+			out.WriteString(text)
+			continue
+		}
+
+		var css []string
+		if span.Style.Bold {
+			css = append(css, "font-weight:bold")
+		}
+		if span.Style.Italic {
+			css = append(css, "font-style:italic")
+		}
+
+		var decorations []string
+		if span.Style.Underline {
+			decorations = append(decorations, "underline")
+		}
+		if span.Style.Strikethrough {
+			decorations = append(decorations, "line-through")
+		}
+		if len(decorations) > 0 {
+			css = append(css, "text-decoration:"+strings.Join(decorations, " "))
+		}
+		if span.Style.Monospace {
+			css = append(css, "font-family:monospace")
+		}
+
+		fg, bg := span.Style.Foreground, span.Style.Background
+		if span.Style.Reverse {
+			// without colors reverse swaps the usual black on white
+			if !fg.Set && !bg.Set {
+				fg, bg = Color{Set: true, Index: White}, Color{Set: true, Index: Black}
+			} else {
+				fg, bg = bg, fg
+			}
+		}
+		if fg.Set {
+			css = append(css, fmt.Sprintf("color:#%06x", fg.Value()))
+		}
+		if bg.Set {
+			css = append(css, fmt.Sprintf("background-color:#%06x", bg.Value()))
+		}
+
+		if len(css) == 0 {
+			out.WriteString(text)
+			continue
+		}
+
+		out.WriteString(`<span style="` + strings.Join(css, ";") + `">` + text + "</span>")
+	}
+
+	return out.String()
+}
+
+//the characters that have a meaning in Markdown
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`, "*", `\*`, "_", `\_`, "~", `\~`, "`", "\\`", "[", `\[`, "]", `\]`,
+	"<", `\<`, ">", `\>`, "#", `\#`, "|", `\|`,
+)
+
+//Markdown renders bold, italic, strikethrough and monospace spans as Markdown. Markdown has no colors or
+//underline, those are dropped
+func Markdown(spans []Span) string {
+	var out strings.Builder
+
+	for _, span := range spans {
+		// markers only work right up against the text, the surrounding spaces go outside them
+		text := strings.TrimSpace(span.Text)
+		if len(text) == 0 {
+			out.WriteString(span.Text)
+			continue
+		}
+		lead := span.Text[:strings.Index(span.Text, text)]
+		trail := span.Text[len(lead)+len(text):]
+
+		if span.Style.Monospace {
+			fence := "`"
+			if strings.Contains(text, "`") {
+				fence = "`` "
+			}
+			text = fence + text + reverse(fence)
+		} else {
+			text = markdownEscaper.Replace(text)
+		}
+
+		if span.Style.Strikethrough {
+			text = "~~" + text + "~~"
+		}
+		if span.Style.Italic {
+			text = "_" + text + "_"
+		}
+		if span.Style.Bold {
+			text = "**" + text + "**"
+		}
+
+		out.WriteString(lead + text + trail)
+	}
+
+	return out.String()
+}
+
+func reverse(text string) string {
+	runes := []rune(text)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+
+	return string(runes)
+}
@@ -0,0 +1,75 @@
+package irc
+
+import (
+	"errors"
+	"strings"
+)
+
+//SetAway marks us as away with the message
+func (c *Client) SetAway(message string) error {
+	message = strings.TrimSpace(message)
+	if len(message) == 0 {
+		return errors.New("An away message is required, use Back to remove it")
+	}
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	return c.server.away(message)
+}
+
+//Back removes our away status
+func (c *Client) Back() error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	return c.server.away("")
+}
+
+//IsAway returns true if the server confirmed we are marked as away
+func (c *Client) IsAway() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.away
+}
+
+//handle our own away replies, RPL_AWAY for other users and AWAY from away-notify
+func (c *Client) handleAway(line IncomingData) {
+	event := EventType{
+		Server:     line.ServerName,
+		Code:       line.Code,
+		Time:       line.Time,
+		ServerTime: line.ServerTime,
+	}
+
+	switch line.Code {
+	case RPL_UNAWAY, RPL_NOWAWAY:
+		c.mu.Lock()
+		c.away = line.Code == RPL_NOWAWAY
+		c.mu.Unlock()
+
+		event.Nick = c.Nick()
+		event.Message = line.Message
+
+	case RPL_AWAY:
+		// <me> <nick> :<message>
+		if len(line.Params) < 3 {
+			return
+		}
+
+		event.Nick = line.Params[1]
+		event.Message = line.Params[2]
+		c.state.away(event.Nick, event.Message, true)
+
+	case RPL_USERAWAY:
+		event.Nick = line.Nick
+		event.Message = line.Message
+		c.state.away(line.Nick, line.Message, len(line.Message) > 0)
+	}
+
+	if callback, ok := c.callbackHandlers[EventAway]; ok {
+		callback(event)
+	}
+}
@@ -0,0 +1,95 @@
+package irc
+
+import "testing"
+
+func TestAway(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	events := make(chan EventType, 5)
+	c.HandleEventFunc(EventAway, func(event EventType) {
+		events <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.negotiate("away-notify")
+	server.register("me")
+	server.send(":me!u@h JOIN #go", ":joe!u@h JOIN #go")
+	eventually(t, "joe to join", func() bool {
+		_, ok := c.User("joe")
+		return ok
+	})
+
+	tests := []struct {
+		line        string
+		away        bool
+		awayMessage string
+	}{
+		{":joe!u@h AWAY :lunch", true, "lunch"},
+		// away-notify without a message means they are back
+		{":joe!u@h AWAY", false, ""},
+		{":srv 301 me joe :gone home", true, "gone home"},
+	}
+
+	for _, test := range tests {
+		server.send(test.line)
+
+		if event := <-events; event.Nick != "joe" || event.Message != test.awayMessage {
+			t.Errorf("%s: got the event for %q %q", test.line, event.Nick, event.Message)
+		}
+		if user, _ := c.User("joe"); user.Away != test.away || user.AwayMessage != test.awayMessage {
+			t.Errorf("%s: joe is away %v %q, want %v %q", test.line, user.Away, user.AwayMessage, test.away, test.awayMessage)
+		}
+	}
+
+	// the member state has the same away info
+	if channel, _ := c.Channel("#go"); len(channel.Members) != 2 || !channel.Members[0].Away {
+		t.Errorf("the members of #go are %+v, want joe to be away", channel.Members)
+	}
+}
+
+func TestSetAway(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	events := make(chan EventType, 5)
+	c.HandleEventFunc(EventAway, func(event EventType) {
+		events <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.expect("USER")
+	server.register("me")
+
+	if err := c.SetAway(" "); err == nil {
+		t.Error("an empty away message was accepted")
+	}
+
+	if err := c.SetAway("brb"); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("AWAY :brb")
+	server.send(":srv 306 me :You have been marked as being away")
+	<-events
+	if !c.IsAway() {
+		t.Error("not away after RPL_NOWAWAY")
+	}
+
+	if err := c.Back(); err != nil {
+		t.Fatal(err)
+	}
+	if line := server.expect("AWAY"); line != "AWAY" {
+		t.Errorf("Back sent %q", line)
+	}
+	server.send(":srv 305 me :You are no longer marked as being away")
+	<-events
+	if c.IsAway() {
+		t.Error("still away after RPL_UNAWAY")
+	}
+}
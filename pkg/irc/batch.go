@@ -0,0 +1,141 @@
+package irc
+
+import (
+	"strings"
+)
+
+//the batch types this package gives special handling
+const (
+	BatchNetsplit    = "netsplit"
+	BatchNetjoin     = "netjoin"
+	BatchChatHistory = "chathistory"
+)
+
+//BatchEvent a complete IRCv3 batch. Messages are the lines of the batch in the order they arrived,
+//Batches are the batches that were nested in this one
+type BatchEvent struct {
+	Ref      string
+	Type     string
+	Params   []string
+	Tags     map[string]string
+	Messages []IncomingData
+	Batches  []*BatchEvent
+
+	parent string
+	start  IncomingData
+}
+
+//collect lines that belong to a batch, returning true if the line was consumed. Once the outer most
+//batch ends it is passed on as a single RPL_BATCH line
+func (c *Client) handleBatch(line IncomingData) (IncomingData, bool) {
+	if line.Code == RPL_BATCH {
+		// a BATCH without a reference is malformed, only completed batches are passed on
+		if len(line.Params) == 0 || len(line.Params[0]) < 2 {
+			return line, true
+		}
+		ref := line.Params[0]
+
+		switch ref[0] {
+		case '+':
+			batch := &BatchEvent{
+				Ref:    ref[1:],
+				Tags:   line.Tags,
+				parent: line.Tags["batch"],
+				start:  line,
+			}
+			if len(line.Params) > 1 {
+				batch.Type = line.Params[1]
+				batch.Params = line.Params[2:]
+			}
+
+			c.batches[batch.Ref] = batch
+			return line, true
+
+		case '-':
+			batch, ok := c.batches[ref[1:]]
+			if !ok {
+				return line, true
+			}
+			delete(c.batches, batch.Ref)
+
+			if parent, ok := c.batches[batch.parent]; ok {
+				parent.Batches = append(parent.Batches, batch)
+				return line, true
+			}
+
+			return c.completeBatch(batch), false
+		}
+	}
+
+	if batch, ok := c.batches[line.Tags["batch"]]; ok {
+		batch.Messages = append(batch.Messages, line)
+		return line, true
+	}
+
+	return line, false
+}
+
+//turn the finished batch into the line that is passed to the hooks and callbacks
+func (c *Client) completeBatch(batch *BatchEvent) IncomingData {
+	line := IncomingData{
+		Code:       RPL_BATCH,
+		CodeName:   "RPL_BATCH",
+		ServerName: batch.start.ServerName,
+		Message:    strings.Join(batch.Params, " "),
+		Params:     batch.Params,
+		Tags:       batch.Tags,
+		Batch:      batch,
+		Time:       batch.start.Time,
+		ServerTime: batch.start.ServerTime,
+	}
+
+	// netsplit and netjoin params are the two servers, chathistory is the target
+	if strings.HasSuffix(batch.Type, BatchChatHistory) && len(batch.Params) > 0 {
+		line.Room = batch.Params[0]
+	}
+
+	return line
+}
+
+//history is replayed, it must not change the channel state
+func (batch *BatchEvent) isHistory() bool {
+	if batch == nil {
+		return false
+	}
+
+	return strings.HasSuffix(batch.Type, BatchChatHistory) || strings.HasSuffix(batch.Type, "chathistory-targets")
+}
+
+//keep the channel state up to date with the lines in the batch, e.g. the QUITs of a netsplit
+func (c *Client) trackBatch(batch *BatchEvent) {
+	if batch == nil || batch.isHistory() {
+		return
+	}
+
+	for _, line := range batch.Messages {
+		c.track(line)
+	}
+
+	for _, nested := range batch.Batches {
+		c.trackBatch(nested)
+	}
+}
+
+//Nicks the users that quit in a netsplit or came back in a netjoin, in the order they were sent
+func (batch *BatchEvent) Nicks() []string {
+	var nicks []string
+	seen := make(map[string]bool)
+
+	for _, line := range batch.Messages {
+		if line.Code != RPL_ROOMQUIT && line.Code != RPL_ROOMJOIN {
+			continue
+		}
+
+		if key := strings.ToLower(line.Nick); !seen[key] {
+			seen[key] = true
+			nicks = append(nicks, line.Nick)
+		}
+	}
+
+	return nicks
+}
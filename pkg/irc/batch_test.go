@@ -0,0 +1,112 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHandleBatch(t *testing.T) {
+	c := NewClient("me", "", "irc.example")
+	c.batches = make(map[string]*BatchEvent)
+
+	lines := []string{
+		":srv BATCH +split netsplit irc.a.example irc.b.example",
+		"@batch=split :joe!u@h QUIT :irc.a.example irc.b.example",
+		// a batch nested in the netsplit
+		"@batch=split :srv BATCH +inner example.com/other",
+		"@batch=inner :srv NOTICE me :inside",
+		"@batch=split :srv BATCH -inner",
+		"@batch=split :ann!u@h QUIT :irc.a.example irc.b.example",
+		"@batch=split :Joe!u@h QUIT :irc.a.example irc.b.example",
+		// not part of any batch
+		":bob!u@h PRIVMSG #go :hi",
+		// the end of a batch that never started
+		":srv BATCH -unknown",
+		":srv BATCH -split",
+	}
+
+	var passed []IncomingData
+	for _, raw := range lines {
+		line, ok := parseRawInput(raw)
+		if !ok {
+			t.Fatalf("%q didn't parse", raw)
+		}
+
+		if line, consumed := c.handleBatch(line); !consumed {
+			passed = append(passed, line)
+		}
+	}
+
+	if len(passed) != 2 || passed[0].Code != RPL_PRIVMSG || passed[1].Code != RPL_BATCH {
+		t.Fatalf("passed on %+v, want the PRIVMSG and the batch", passed)
+	}
+
+	batch := passed[1].Batch
+	if batch == nil || batch.Ref != "split" || batch.Type != BatchNetsplit {
+		t.Fatalf("got the batch %+v", batch)
+	}
+	if want := []string{"irc.a.example", "irc.b.example"}; !reflect.DeepEqual(batch.Params, want) {
+		t.Errorf("params %v, want %v", batch.Params, want)
+	}
+	if len(batch.Messages) != 3 {
+		t.Errorf("the batch has %d messages, want 3", len(batch.Messages))
+	}
+	if want := []string{"joe", "ann"}; !reflect.DeepEqual(batch.Nicks(), want) {
+		t.Errorf("Nicks() = %v, want %v", batch.Nicks(), want)
+	}
+
+	if len(batch.Batches) != 1 || batch.Batches[0].Type != "example.com/other" || len(batch.Batches[0].Messages) != 1 {
+		t.Errorf("nested batches %+v", batch.Batches)
+	}
+	if len(c.batches) != 0 {
+		t.Errorf("batches left open: %v", c.batches)
+	}
+}
+
+func TestHistoryBatch(t *testing.T) {
+	tests := []struct {
+		kind    string
+		history bool
+		room    string
+	}{
+		{BatchChatHistory, true, "#go"},
+		{"draft/chathistory", true, "#go"},
+		// the params of a targets batch aren't a room
+		{"draft/chathistory-targets", true, ""},
+		{BatchNetjoin, false, ""},
+	}
+
+	c := NewClient("me", "", "irc.example")
+	for _, test := range tests {
+		batch := &BatchEvent{Type: test.kind, Params: []string{"#go"}}
+		if got := batch.isHistory(); got != test.history {
+			t.Errorf("%s: isHistory() = %v", test.kind, got)
+		}
+
+		if line := c.completeBatch(batch); line.Room != test.room {
+			t.Errorf("%s: the batch line has the room %q, want %q", test.kind, line.Room, test.room)
+		}
+	}
+}
+
+func TestHandleBatchMalformed(t *testing.T) {
+	c := NewClient("me", "", "irc.example")
+	c.batches = make(map[string]*BatchEvent)
+
+	for _, raw := range []string{":srv BATCH", ":srv BATCH +", ":srv BATCH -"} {
+		line, ok := parseRawInput(raw)
+		if !ok {
+			t.Fatalf("%q didn't parse", raw)
+		}
+
+		if _, consumed := c.handleBatch(line); !consumed {
+			t.Errorf("%q was passed on", raw)
+		}
+	}
+
+	// a RPL_BATCH line without a batch doesn't panic the state tracking or the history check
+	c.track(IncomingData{Code: RPL_BATCH, CodeName: "RPL_BATCH"})
+	if (*BatchEvent)(nil).isHistory() {
+		t.Error("a nil batch is history")
+	}
+}
@@ -0,0 +1,138 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+//the address families a connection can use
+const (
+	//FamilyAny tries the server's addresses in the order the resolver returned them, alternating families
+	FamilyAny = ""
+	//PreferIPv4 tries the IPv4 addresses first, alternating with IPv6
+	PreferIPv4 = "prefer-ipv4"
+	//PreferIPv6 tries the IPv6 addresses first, alternating with IPv4
+	PreferIPv6 = "prefer-ipv6"
+	//OnlyIPv4 never connects over IPv6
+	OnlyIPv4 = "ipv4"
+	//OnlyIPv6 never connects over IPv4
+	OnlyIPv6 = "ipv6"
+)
+
+//bindAddrs resolves the bind address, a host name is a vhost and may have an address of each family. An
+//IPv6 link-local address needs its zone, e.g. fe80::1%eth0. Addresses that aren't assigned to this host are
+//dropped, it is an error when none are left
+func (s *Server) bindAddrs(ctx context.Context) ([]net.IPAddr, error) {
+	if len(s.bindAddress) == 0 {
+		return nil, nil
+	}
+
+	var ips []net.IPAddr
+	if addr, err := netip.ParseAddr(s.bindAddress); err == nil {
+		ips = append(ips, net.IPAddr{IP: net.IP(addr.Unmap().AsSlice()), Zone: addr.Zone()})
+	} else {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, s.bindAddress)
+		if err != nil {
+			return nil, fmt.Errorf("Can't resolve the bind address %s: %v", s.bindAddress, err)
+		}
+		ips = addrs
+	}
+
+	var binds []net.IPAddr
+	var bindErr error
+	for _, ip := range ips {
+		if !familyAllowed(s.family, ip.IP) {
+			continue
+		}
+
+		//listening on the address is the quickest way to find out if it is ours
+		listener, err := net.Listen("tcp", net.JoinHostPort(ip.String(), "0"))
+		if err != nil {
+			bindErr = err
+			continue
+		}
+		listener.Close()
+
+		binds = append(binds, ip)
+	}
+
+	if len(binds) == 0 {
+		if bindErr == nil {
+			return nil, fmt.Errorf("Can't bind to %s, it has no address of the allowed family", s.bindAddress)
+		}
+		return nil, fmt.Errorf("Can't bind to %s: %v", s.bindAddress, bindErr)
+	}
+
+	return binds, nil
+}
+
+//localAddr the bind address of the same family as ip, nil lets the system choose
+func localAddr(binds []net.IPAddr, ip net.IP) net.Addr {
+	for _, bind := range binds {
+		if (bind.IP.To4() != nil) == (ip.To4() != nil) {
+			return &net.TCPAddr{IP: bind.IP, Zone: bind.Zone}
+		}
+	}
+
+	return nil
+}
+
+//proxyLocalAddr the bind address used for the proxy, whose address is resolved by the dialer. The dialer only
+//connects to proxy addresses of the same family as the bind address
+func proxyLocalAddr(binds []net.IPAddr, family string) net.Addr {
+	if len(binds) == 0 {
+		return nil
+	}
+
+	for _, bind := range binds {
+		isV4 := bind.IP.To4() != nil
+		if (isV4 && family == PreferIPv4) || (!isV4 && family == PreferIPv6) {
+			return &net.TCPAddr{IP: bind.IP, Zone: bind.Zone}
+		}
+	}
+
+	return &net.TCPAddr{IP: binds[0].IP, Zone: binds[0].Zone}
+}
+
+//usable is true when the family setting allows ip and, when we bind, there is a bind address of its family
+func usable(family string, binds []net.IPAddr, ip net.IP) bool {
+	if !familyAllowed(family, ip) {
+		return false
+	}
+
+	return len(binds) == 0 || localAddr(binds, ip) != nil
+}
+
+func familyAllowed(family string, ip net.IP) bool {
+	switch family {
+	case OnlyIPv4:
+		return ip.To4() != nil
+	case OnlyIPv6:
+		return ip.To4() == nil
+	default:
+		return true
+	}
+}
+
+//the network passed to the dialer when it resolves the address itself
+func network(family string) string {
+	switch family {
+	case OnlyIPv4:
+		return "tcp4"
+	case OnlyIPv6:
+		return "tcp6"
+	default:
+		return "tcp"
+	}
+}
+
+func validFamily(family string) bool {
+	switch family {
+	case FamilyAny, PreferIPv4, PreferIPv6, OnlyIPv4, OnlyIPv6:
+		return true
+	default:
+		return false
+	}
+}
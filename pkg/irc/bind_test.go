@@ -0,0 +1,184 @@
+package irc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestUsable(t *testing.T) {
+	v4, v6 := net.ParseIP("192.0.2.1"), net.ParseIP("2001:db8::1")
+	bindV4 := []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}}
+
+	tests := []struct {
+		family string
+		binds  []net.IPAddr
+		ip     net.IP
+		want   bool
+	}{
+		{FamilyAny, nil, v4, true},
+		{FamilyAny, nil, v6, true},
+		{PreferIPv6, nil, v4, true},
+		{OnlyIPv4, nil, v4, true},
+		{OnlyIPv4, nil, v6, false},
+		{OnlyIPv6, nil, v4, false},
+		{OnlyIPv6, nil, v6, true},
+		{FamilyAny, bindV4, v4, true},
+		// there is no IPv6 address to bind to
+		{FamilyAny, bindV4, v6, false},
+		{OnlyIPv6, bindV4, v4, false},
+	}
+
+	for _, test := range tests {
+		if got := usable(test.family, test.binds, test.ip); got != test.want {
+			t.Errorf("usable(%q, %v, %s) = %v, want %v", test.family, test.binds, test.ip, got, test.want)
+		}
+	}
+}
+
+func TestLocalAddr(t *testing.T) {
+	binds := []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("fe80::1"), Zone: "eth0"}}
+
+	tests := []struct {
+		binds []net.IPAddr
+		ip    string
+		want  string
+	}{
+		{nil, "192.0.2.1", ""},
+		{binds, "192.0.2.1", "10.0.0.1:0"},
+		// the zone of a link-local bind address is kept
+		{binds, "2001:db8::1", "[fe80::1%eth0]:0"},
+		{binds[:1], "2001:db8::1", ""},
+	}
+
+	for _, test := range tests {
+		got := ""
+		if addr := localAddr(test.binds, net.ParseIP(test.ip)); addr != nil {
+			got = addr.String()
+		}
+
+		if got != test.want {
+			t.Errorf("localAddr(%v, %s) = %q, want %q", test.binds, test.ip, got, test.want)
+		}
+	}
+}
+
+func TestProxyLocalAddr(t *testing.T) {
+	binds := []net.IPAddr{{IP: net.ParseIP("10.0.0.1")}, {IP: net.ParseIP("fe80::1"), Zone: "eth0"}}
+
+	tests := []struct {
+		binds  []net.IPAddr
+		family string
+		want   string
+	}{
+		{nil, FamilyAny, ""},
+		{binds, FamilyAny, "10.0.0.1:0"},
+		{binds, PreferIPv4, "10.0.0.1:0"},
+		{binds, PreferIPv6, "[fe80::1%eth0]:0"},
+		{binds[1:], PreferIPv4, "[fe80::1%eth0]:0"},
+	}
+
+	for _, test := range tests {
+		got := ""
+		if addr := proxyLocalAddr(test.binds, test.family); addr != nil {
+			got = addr.String()
+		}
+
+		if got != test.want {
+			t.Errorf("proxyLocalAddr(%v, %q) = %q, want %q", test.binds, test.family, got, test.want)
+		}
+	}
+}
+
+func TestNetwork(t *testing.T) {
+	tests := map[string]string{FamilyAny: "tcp", PreferIPv4: "tcp", PreferIPv6: "tcp", OnlyIPv4: "tcp4", OnlyIPv6: "tcp6"}
+
+	for family, want := range tests {
+		if got := network(family); got != want {
+			t.Errorf("network(%q) = %q, want %q", family, got, want)
+		}
+		if !validFamily(family) {
+			t.Errorf("%q isn't a valid family", family)
+		}
+	}
+
+	if validFamily("ipv5") {
+		t.Error("ipv5 is a valid family")
+	}
+}
+
+func TestBindAddrs(t *testing.T) {
+	tests := []struct {
+		address string
+		family  string
+		want    string
+		wantErr string
+	}{
+		{"", FamilyAny, "", ""},
+		{"127.0.0.1", FamilyAny, "127.0.0.1", ""},
+		{"127.0.0.1", OnlyIPv6, "", "no address of the allowed family"},
+		// an address that isn't assigned to this host
+		{"192.0.2.1", FamilyAny, "", "Can't bind to 192.0.2.1"},
+	}
+
+	for _, test := range tests {
+		s := &Server{bindAddress: test.address, family: test.family}
+		binds, err := s.bindAddrs(context.Background())
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("bindAddrs(%q) got the error %v, want one containing %q", test.address, err, test.wantErr)
+			}
+			continue
+		}
+
+		var got []string
+		for _, bind := range binds {
+			got = append(got, bind.String())
+		}
+		if err != nil || strings.Join(got, " ") != test.want {
+			t.Errorf("bindAddrs(%q) = %v, %v, want %s", test.address, got, err, test.want)
+		}
+	}
+}
+
+func TestBindAddrsZone(t *testing.T) {
+	address := linkLocalAddress()
+	if len(address) == 0 {
+		t.Skip("no interface has an IPv6 link-local address")
+	}
+
+	s := &Server{bindAddress: address}
+	binds, err := s.bindAddrs(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(binds) != 1 || binds[0].String() != address {
+		t.Errorf("bindAddrs(%q) = %v", address, binds)
+	}
+}
+
+//an IPv6 link-local address of this host with its zone, e.g. fe80::1%eth0
+func linkLocalAddress() string {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return ""
+	}
+
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.To4() == nil && ipNet.IP.IsLinkLocalUnicast() {
+				return ipNet.IP.String() + "%" + iface.Name
+			}
+		}
+	}
+
+	return ""
+}
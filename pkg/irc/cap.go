@@ -0,0 +1,131 @@
+package irc
+
+import (
+	"strings"
+)
+
+//the IRCv3 capabilities this package knows how to handle
+var supportedCapabilities = []string{
+	"account-notify",
+	"account-tag",
+	"away-notify",
+	"batch",
+	"cap-notify",
+	"chghost",
+	"draft/chathistory",
+	"echo-message",
+	"extended-join",
+	"invite-notify",
+	"labeled-response",
+	"message-tags",
+	"multi-prefix",
+	"server-time",
+	"setname",
+	"userhost-in-names",
+}
+
+//HasCapability returns true if the server acknowledged the capability
+func (c *Client) HasCapability(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.caps[strings.ToLower(name)]
+}
+
+//handle the CAP negotiation: CAP <nick> <subcommand> [*] :<capabilities>
+func (c *Client) handleCap(line IncomingData) {
+	if len(line.Params) < 3 {
+		return
+	}
+
+	sub := strings.ToUpper(line.Params[1])
+	list := line.Params[len(line.Params)-1]
+	more := len(line.Params) > 3 && line.Params[2] == "*"
+
+	switch sub {
+	case "LS", "NEW":
+		c.mu.Lock()
+		for _, capability := range strings.Fields(list) {
+			name := capability
+			value := ""
+			if index := strings.Index(capability, "="); index != -1 {
+				name, value = capability[:index], capability[index+1:]
+			}
+			c.capOffered[strings.ToLower(name)] = value
+		}
+		c.mu.Unlock()
+
+		// multiline LS replies end with the line without the *
+		if sub == "LS" && more {
+			return
+		}
+		c.requestCaps()
+
+	case "ACK":
+		c.mu.Lock()
+		for _, capability := range strings.Fields(list) {
+			if strings.HasPrefix(capability, "-") {
+				delete(c.caps, strings.ToLower(capability[1:]))
+				continue
+			}
+			c.caps[strings.ToLower(capability)] = true
+		}
+		c.capPending--
+		c.mu.Unlock()
+		c.endCap()
+
+	case "NAK":
+		c.mu.Lock()
+		c.capPending--
+		c.mu.Unlock()
+		c.endCap()
+
+	case "DEL":
+		c.mu.Lock()
+		for _, capability := range strings.Fields(list) {
+			delete(c.caps, strings.ToLower(capability))
+			delete(c.capOffered, strings.ToLower(capability))
+		}
+		c.mu.Unlock()
+	}
+}
+
+//request every wanted capability the server offered that we don't have yet
+func (c *Client) requestCaps() {
+	var request []string
+
+	c.mu.Lock()
+	for _, capability := range c.Capabilities {
+		capability = strings.ToLower(capability)
+		if _, offered := c.capOffered[capability]; offered && !c.caps[capability] {
+			request = append(request, capability)
+		}
+	}
+
+	if len(request) > 0 {
+		c.capPending++
+	}
+	c.mu.Unlock()
+
+	if len(request) > 0 {
+		c.server.capability("REQ", request...)
+		return
+	}
+
+	c.endCap()
+}
+
+//finish the negotiation once every request was answered, this lets registration continue
+func (c *Client) endCap() {
+	c.mu.Lock()
+	done := c.capPending <= 0 && !c.registered && !c.capEnded
+	if done {
+		c.capPending = 0
+		c.capEnded = true
+	}
+	c.mu.Unlock()
+
+	if done {
+		c.server.capability("END")
+	}
+}
@@ -2,8 +2,11 @@ package irc
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -16,6 +19,16 @@ const (
 	EventChannelMessage = "CHANNELMESSAGE"
 	EventMOTD           = "EVENTMOTD"
 	EventMessage        = "EVENTMESSAGE"
+	EventOnline         = "ONLINE"
+	EventOffline        = "OFFLINE"
+	EventAway           = "AWAY"
+	EventBatch          = "BATCH"
+	EventNotice         = "NOTICE"
+	EventAccount        = "ACCOUNT"
+	EventChangeHost     = "CHGHOST"
+	EventSetName        = "SETNAME"
+	EventInvite         = "INVITE"
+	EventServerNotice   = "SERVERNOTICE"
 )
 
 //EventType the data that will be sent to the EventCallback func
@@ -24,32 +37,142 @@ type EventType struct {
 	Server  string
 	Nick    string
 	Room    string
+	Rooms   []string
+	Target  string
+	Account string
 	Code    int32
 	Err     error
 	Time    time.Time
+	//ServerTime is true when Time is the server-time tag of the message, otherwise it is the local time it arrived
+	ServerTime bool
+	//Batch is set for EventBatch
+	Batch *BatchEvent
+	//Names is set for RPL_NAMREPLY
+	Names *NamesReply
+	//ServerNotice is set for EventServerNotice
+	ServerNotice *ServerNotice
+	//Network is the name of the network the event came from when the client is run by a Manager
+	Network string
 }
 
 //EventCallback the function signature for callback events
 type EventCallback func(EventType)
 
+//lineHook is handed every line from the server before it is dispatched, returning true removes the hook
+type lineHook func(IncomingData) bool
+
+type hookEntry struct {
+	id   int
+	hook lineHook
+}
+
 //Client object describing the irc connection
 type Client struct {
-	IRCServer        string
-	UserName         string
-	Pass             string
+	IRCServer string
+	UserName  string
+	Pass      string
+	//Servers the servers of the network in the order they are tried, IRCServer is used when this is empty.
+	//Every address a host resolves to is tried before moving on to the next server
+	Servers []ServerAddress
+	//TLSConfig is used for servers with UseTLS, nil checks the certificate against the host
+	TLSConfig *tls.Config
+	//Proxy the connection goes through, nil connects directly
+	Proxy *Proxy
+	//BindAddress the local IP or vhost name connections are made from, "" lets the system choose. An error
+	//binding to it is sent to EventError
+	BindAddress string
+	//AddressFamily restricts or orders the IP versions used to connect, e.g. PreferIPv6 or OnlyIPv4
+	AddressFamily string
+	//AltNicks are tried in order when UserName is taken while registering, NickFallback is used after that
+	AltNicks []string
+	//NickFallback generates a nick for the attempt once AltNicks ran out, returning "" gives up
+	NickFallback func(nick string, attempt int) string
+	//RegainNickFreq how often to try to get UserName back when using another nick, 0 disables this
+	RegainNickFreq time.Duration
+	//PresenceFreq how often watched nicks are polled with ISON when MONITOR isn't available
+	PresenceFreq time.Duration
+	//AutoJoinInvites joins the channel when an invite matches the policy, nil never joins
+	AutoJoinInvites *InvitePolicy
+	//AutoJoin the channels joined once registration is complete and the OnRegister actions ran
+	AutoJoin []ChannelKey
+	//OnRegister actions run in order once registration is complete, e.g. identify or set modes
+	OnRegister []RegisterAction
+	//RegisterTimeout how long each OnRegister action may take, 0 doesn't limit them
+	RegisterTimeout time.Duration
+	//Encoding is used for lines that aren't valid UTF-8 and for the lines we send, nil is UTF-8 only.
+	//SetChannelEncoding overrides it for a channel
+	Encoding *Encoding
+	//Capabilities the IRCv3 capabilities requested when the server offers them
+	Capabilities []string
+
 	server           Server
 	callbackHandlers map[string]EventCallback
+	isupport         *ISupport
+	presence         *presence
+	state            *tracker
+	batches          map[string]*BatchEvent
+
+	mu       sync.Mutex
+	hooks    []hookEntry
+	hookID   int
+	whoToken int
+	//whoSent and whoEnded count the WHO queries sent and the RPL_ENDOFWHO received for each mask
+	whoSent   map[string]int
+	whoEnded  map[string]int
+	whoMu     sync.Mutex
+	historyMu sync.Mutex
+	statsMu   sync.Mutex
+	commands  *commandRegistry
+
+	channelEncodings map[string]*Encoding
+	nick             string
+	registered       bool
+	ready            bool
+	regaining        bool
+	nickAttempts     int
+	away             bool
+	caps             map[string]bool
+	capOffered       map[string]string
+	capPending       int
+	capEnded         bool
+	labelID          int
+	pendingSends     []*pendingSend
+	connCtx          context.Context
+	connCancel       context.CancelFunc
+	identifyReply    *pendingReply
 }
 
 //NewClient new client object with a defaut server setup
 func NewClient(nick, password, serverName string) *Client {
-	return &Client{
+	c := &Client{
 		UserName:         nick,
 		Pass:             password,
 		IRCServer:        serverName,
+		NickFallback:     DefaultNickFallback,
+		PresenceFreq:     time.Minute,
+		RegisterTimeout:  time.Minute,
+		Capabilities:     append([]string{}, supportedCapabilities...),
 		callbackHandlers: make(map[string]EventCallback),
 		server:           NewIRCServer(serverName, false),
+		isupport:         newISupport(),
+		presence:         newPresence(),
+		state:            newTracker(),
+		commands:         newCommandRegistry(),
+		batches:          make(map[string]*BatchEvent),
+		caps:             make(map[string]bool),
+		capOffered:       make(map[string]string),
+		channelEncodings: make(map[string]*Encoding),
 	}
+
+	c.server.decode = c.decodeLine
+	c.server.encode = c.encodeLine
+
+	return c
+}
+
+//ISupport returns the features advertised by the server, this is filled in after the connection is registered
+func (c *Client) ISupport() *ISupport {
+	return c.isupport
 }
 
 //HandleEventFunc handle event callbacks
@@ -57,12 +180,57 @@ func (c *Client) HandleEventFunc(event string, cb EventCallback) {
 	c.callbackHandlers[event] = cb
 }
 
-//StartConnection connect to the irc server supplied in the Client object
+//StartConnection connect to the irc server supplied in the Client object. A server that redirects us with
+//RPL_BOUNCE or RPL_REDIR is connected to right away
 func (c *Client) StartConnection() {
-	connectCtx, cancel := context.WithCancel(context.Background())
+	c.StartConnectionContext(context.Background())
+}
+
+//StartConnectionContext is StartConnection that stops connecting, or disconnects, once the ctx is done
+func (c *Client) StartConnectionContext(ctx context.Context) {
+	for redirects := 0; c.connect(ctx) && ctx.Err() == nil; redirects++ {
+		if redirects == maxRedirects {
+			c.server.takeRedirect()
+
+			if callback, ok := c.callbackHandlers[EventError]; ok {
+				callback(EventType{
+					Err: errors.New("Too many server redirects"),
+				})
+			}
+			return
+		}
+	}
+}
+
+//connect blocks until the connection is closed, it returns true when it was closed to follow a redirect
+func (c *Client) connect(ctx context.Context) bool {
+	connectCtx, cancel := context.WithCancel(ctx)
+
+	c.mu.Lock()
+	c.nick = c.UserName
+	c.registered = false
+	c.ready = false
+	c.nickAttempts = 0
+	c.away = false
+	c.caps = make(map[string]bool)
+	c.whoSent = make(map[string]int)
+	c.whoEnded = make(map[string]int)
+	c.capOffered = make(map[string]string)
+	c.capPending = 0
+	c.capEnded = false
+	c.connCtx = connectCtx
+	c.connCancel = cancel
+	c.mu.Unlock()
+
+	c.server.servers = c.Servers
+	c.server.tlsConfig = c.TLSConfig
+	c.server.proxy = c.Proxy
+	c.server.bindAddress = c.BindAddress
+	c.server.family = c.AddressFamily
 
 	if err := c.server.start(connectCtx, c.UserName, c.Pass); err != nil {
-		if callback, ok := c.callbackHandlers[EventError]; ok {
+		// being stopped while connecting isn't an error
+		if callback, ok := c.callbackHandlers[EventError]; ok && connectCtx.Err() == nil {
 			callback(EventType{
 				Err: err,
 			})
@@ -75,37 +243,167 @@ func (c *Client) StartConnection() {
 			})
 		}
 		cancel()
-		return
+		return false
 	}
 
 	if callback, ok := c.callbackHandlers[EventConnect]; ok {
-		callback(EventType{})
+		callback(EventType{
+			Server: c.server.address.String(),
+		})
 	}
 
+	if c.RegainNickFreq > 0 {
+		go c.regainNick(connectCtx)
+	}
+	if c.PresenceFreq > 0 {
+		go c.presenceTicker(connectCtx)
+	}
+
+	stop := context.AfterFunc(ctx, c.server.disconnect)
+	defer stop()
+
 	c.listenToChannels(cancel)
+	return c.server.redirecting()
 }
 
-//WriteToTarget will send the message to the target, e.g the room, a user etc
+//WriteToTarget will send the message to the target, e.g the room, a user etc. Use SendMessage to find out
+//if the server accepted it
 func (c *Client) WriteToTarget(target string, message string) {
-	c.server.privMessage(target, message)
+	c.SendMessage(target, message)
 }
 
 //StopConnection closes and disconnects from the irc server. This will stop the blocking nature of
+//StartConnection, including while it is still connecting. It must not be called from an event callback
 func (c *Client) StopConnection() {
+	c.mu.Lock()
+	cancel := c.connCancel
+	c.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
 	c.server.close()
 }
 
-//Command send an irc command
-func (c *Client) Command(command Command) {
-	// trim and make everything lower case
-	if len(command.Action) > 0 {
-		command.Action = strings.ToLower(strings.TrimSpace(command.Action))
+//Quit sends QUIT with the message, the server then closes the connection and StartConnection returns
+func (c *Client) Quit(message string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	return c.server.send(NewMessage("QUIT").Text(message))
+}
+
+//Command sends a command from the registry, e.g. join, kick or one added with RegisterCommand. It returns
+//an error for unknown commands or args the command doesn't accept
+func (c *Client) Command(command Command) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	// trim everything, the args keep their case for channel keys and messages
+	action := strings.TrimSpace(command.Action)
+	spec, ok := c.commands.get(action)
+	if !ok {
+		return fmt.Errorf("Unknown command %q", action)
+	}
+
+	var args []string
+	for _, arg := range command.Args {
+		if arg = strings.TrimSpace(arg); len(arg) > 0 {
+			args = append(args, arg)
+		}
+	}
+
+	lines, err := spec.lines(args)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		if err := c.server.write(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//isReady returns true once registration is complete and the MOTD was sent
+func (c *Client) isReady() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.ready
+}
+
+//called at the end of the MOTD, by now the server has sent RPL_ISUPPORT
+func (c *Client) onReady() {
+	c.mu.Lock()
+	if c.ready {
+		c.mu.Unlock()
+		return
+	}
+	c.ready = true
+	ctx := c.connCtx
+	c.mu.Unlock()
+
+	c.startPresence()
+
+	// actions may wait on replies from the server, they can't block the listen loop
+	go c.afterRegister(ctx)
+}
+
+//use the server-time tag as the time of the line when the capability is enabled
+func (c *Client) applyServerTime(line *IncomingData) {
+	stamp, ok := line.Tags["time"]
+	if !ok || !c.HasCapability("server-time") {
+		return
+	}
+
+	if serverTime, err := time.Parse(time.RFC3339Nano, stamp); err == nil {
+		line.Time = serverTime
+		line.ServerTime = true
+	}
+}
+
+//addHook registers a hook to see every incoming line, the returned func removes it
+func (c *Client) addHook(hook lineHook) func() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.hookID++
+	id := c.hookID
+	c.hooks = append(c.hooks, hookEntry{id: id, hook: hook})
+
+	return func() {
+		c.removeHook(id)
+	}
+}
+
+func (c *Client) removeHook(id int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 
-		for index, comm := range command.Args {
-			command.Args[index] = strings.ToLower(strings.TrimSpace(comm))
+	for index, entry := range c.hooks {
+		if entry.id == id {
+			c.hooks = append(c.hooks[:index], c.hooks[index+1:]...)
+			return
 		}
+	}
+}
+
+//pass the line to every registered hook in the order they were added
+func (c *Client) runHooks(line IncomingData) {
+	c.mu.Lock()
+	hooks := make([]hookEntry, len(c.hooks))
+	copy(hooks, c.hooks)
+	c.mu.Unlock()
 
-		c.server.sendChan <- command
+	for _, entry := range hooks {
+		if entry.hook(line) {
+			c.removeHook(entry.id)
+		}
 	}
 }
 
@@ -114,54 +412,103 @@ func (c *Client) listenToChannels(cancel context.CancelFunc) {
 	for {
 		select {
 		case line := <-c.server.recvChan:
+			c.applyServerTime(&line)
+
+			line, consumed := c.handleBatch(line)
+			if consumed {
+				continue
+			}
+
+			c.countWhoEnd(line)
+			c.runHooks(line)
+
+			// QUIT and NICK don't name a channel, list the ones we shared before the state forgets them
+			if line.Code == RPL_ROOMQUIT || line.Code == RPL_NICKCHANGE {
+				line.Rooms = c.state.channelsOf(line.Nick)
+			}
+			c.track(line)
+
+			// our own messages echoed back are only used to resolve SendMessage
+			if c.resolveSends(line) {
+				continue
+			}
+
 			switch line.Code {
-			case RPL_WELCOME, RPL_YOURHOST, RPL_CREATED, RPL_MYINFO, RPL_BOUNCE:
+			case RPL_WELCOME, RPL_YOURHOST, RPL_CREATED, RPL_MYINFO, RPL_BOUNCE, RPL_REDIR:
+				if server, ok := parseRedirect(line, c.server.address); ok {
+					c.server.redirectTo(server)
+				}
+				if line.Code == RPL_WELCOME {
+					c.setRegistered(line.Nick)
+				}
+				if line.CodeName == "RPL_ISUPPORT" && len(line.Params) > 2 {
+					// the first param is our nick and the last is the "are supported by this server" text
+					c.isupport.update(line.Params[1 : len(line.Params)-1])
+				}
+
 				if callback, ok := c.callbackHandlers[EventConnect]; ok {
 					callback(EventType{
-						Message: line.Message,
-						Server:  line.ServerName,
-						Code:    line.Code,
-						Time:    line.Time,
+						Message:    line.Message,
+						Server:     line.ServerName,
+						Code:       line.Code,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
 					})
 				}
 
-			case RPL_MOTD, RPL_ENDOFMOTD:
+			case RPL_MOTD, RPL_ENDOFMOTD, ERR_NOMOTD:
+				if line.Code != RPL_MOTD {
+					c.onReady()
+				}
+
 				if callback, ok := c.callbackHandlers[EventMOTD]; ok {
 					callback(EventType{
-						Message: line.Message,
-						Code:    line.Code,
-						Server:  line.ServerName,
-						Time:    line.Time,
+						Message:    line.Message,
+						Code:       line.Code,
+						Server:     line.ServerName,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
 					})
 				}
 
 			case RPL_LIST, RPL_LISTEND, RPL_FORWARDJOIN, RPL_NAMREPLY, RPL_ENDOFNAMES:
 				if callback, ok := c.callbackHandlers[EventChannelMessage]; ok {
 					callback(EventType{
-						Server:  line.ServerName,
-						Code:    line.Code,
-						Nick:    line.Nick,
-						Room:    line.Room,
-						Time:    line.Time,
-						Message: line.Message,
+						Server:     line.ServerName,
+						Code:       line.Code,
+						Nick:       line.Nick,
+						Room:       line.Room,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
+						Message:    line.Message,
+						Names:      c.parseNames(line),
 					})
 				}
 
-			case RPL_TOPIC, RPL_ROOMJOIN, RPL_ROOMPART, RPL_ROOMQUIT:
+			case RPL_NOTOPIC, RPL_TOPIC, RPL_TOPICWHOTIME, RPL_ROOMJOIN, RPL_ROOMPART, RPL_ROOMQUIT, RPL_ROOMKICK, RPL_ROOMTOPIC, RPL_NICKCHANGE:
+				if line.Code == RPL_NICKCHANGE {
+					c.nickChanged(line.Nick, line.Message)
+				}
+
 				if callback, ok := c.callbackHandlers[EventRoomMessage]; ok {
 					callback(EventType{
-						Server:  line.ServerName,
-						Code:    line.Code,
-						Nick:    line.Nick,
-						Room:    line.Room,
-						Message: line.Message,
-						Time:    line.Time,
+						Server:     line.ServerName,
+						Code:       line.Code,
+						Nick:       line.Nick,
+						Room:       line.Room,
+						Rooms:      line.Rooms,
+						Target:     line.Target,
+						Message:    line.Message,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
+						Account:    line.Account,
 					})
 				}
 
 			case
 				RPL_ERRORJOIN, ERR_BADMASK, ERR_CANNOTSENDTOCHAN, ERR_NORECIPIENT, ERR_NOSUCHCHANNEL,
-				ERR_NOSUCHNICK, ERR_NOSUCHSERVER, ERR_NOTEXTTOSEND, ERR_NOTOPLEVEL, ERR_TOOMANYCHANNELS, ERR_WASNOSUCHNICK:
+				ERR_NOSUCHNICK, ERR_NOSUCHSERVER, ERR_NOTEXTTOSEND, ERR_NOTOPLEVEL, ERR_TOOMANYCHANNELS, ERR_WASNOSUCHNICK,
+				ERR_INVITEONLYCHAN, ERR_BANNEDFROMCHAN, ERR_BADCHANNELKEY:
 				if callback, ok := c.callbackHandlers[EventError]; ok {
 					callback(EventType{
 						Server:  line.ServerName,
@@ -172,15 +519,98 @@ func (c *Client) listenToChannels(cancel context.CancelFunc) {
 					})
 				}
 
+			case ERR_NONICKNAMEGIVEN, ERR_ERRONEUSNICKNAME, ERR_NICKNAMEINUSE, ERR_NICKCOLLISION, ERR_UNAVAILRESOURCE:
+				c.nickRejected(line)
+
+			case RPL_ISON, RPL_MONONLINE, RPL_MONOFFLINE, ERR_MONLISTFULL:
+				c.handlePresence(line)
+
+			case RPL_CAP:
+				c.handleCap(line)
+
+			case RPL_CHGHOST, RPL_SETNAME:
+				event := EventChangeHost
+				if line.Code == RPL_SETNAME {
+					event = EventSetName
+				}
+
+				if callback, ok := c.callbackHandlers[event]; ok {
+					callback(EventType{
+						Nick:       line.Nick,
+						Code:       line.Code,
+						Account:    line.Account,
+						Message:    line.Message,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
+					})
+				}
+
+			case RPL_INVITE, RPL_INVITING:
+				c.handleInvite(line)
+
+			case RPL_ACCOUNT:
+				if callback, ok := c.callbackHandlers[EventAccount]; ok {
+					callback(EventType{
+						Nick:       line.Nick,
+						Account:    line.Account,
+						Message:    line.Account,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
+					})
+				}
+
+			case RPL_BATCH:
+				if callback, ok := c.callbackHandlers[EventBatch]; ok {
+					callback(EventType{
+						Server:     line.ServerName,
+						Code:       line.Code,
+						Room:       line.Room,
+						Message:    line.Message,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
+						Batch:      line.Batch,
+					})
+				}
+
+			case RPL_AWAY, RPL_UNAWAY, RPL_NOWAWAY, RPL_USERAWAY:
+				c.handleAway(line)
+
+			case RPL_NOTICE:
+				if callback, ok := c.callbackHandlers[EventServerNotice]; ok && isServerNotice(line) {
+					callback(EventType{
+						Server:       line.ServerName,
+						Code:         line.Code,
+						Message:      line.Message,
+						Time:         line.Time,
+						ServerTime:   line.ServerTime,
+						ServerNotice: parseServerNotice(line.Message),
+					})
+				}
+
+				if callback, ok := c.callbackHandlers[EventNotice]; ok {
+					callback(EventType{
+						Server:     line.ServerName,
+						Code:       line.Code,
+						Nick:       line.Nick,
+						Room:       line.Room,
+						Message:    line.Message,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
+						Account:    line.Account,
+					})
+				}
+
 			case RPL_PRIVMSG:
 				if callback, ok := c.callbackHandlers[EventMessage]; ok {
 					callback(EventType{
-						Server:  line.ServerName,
-						Code:    line.Code,
-						Nick:    line.Nick,
-						Room:    line.Room,
-						Message: line.Message,
-						Time:    line.Time,
+						Server:     line.ServerName,
+						Code:       line.Code,
+						Nick:       line.Nick,
+						Room:       line.Room,
+						Message:    line.Message,
+						Time:       line.Time,
+						ServerTime: line.ServerTime,
+						Account:    line.Account,
 					})
 				}
 			}
@@ -202,6 +632,17 @@ func (c *Client) listenToChannels(cancel context.CancelFunc) {
 
 		case <-c.server.closeChan:
 			cancel()
+			c.isupport.reset()
+
+			c.resetPresence()
+			c.state.reset()
+			c.batches = make(map[string]*BatchEvent)
+			c.failPendingSends()
+
+			c.mu.Lock()
+			c.registered = false
+			c.ready = false
+			c.mu.Unlock()
 			if callback, ok := c.callbackHandlers[EventDisconnect]; ok {
 				callback(EventType{})
 			}
@@ -1,7 +1,7 @@
 package irc
 
 import (
-	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -10,105 +10,249 @@ type Command struct {
 	Args   []string
 }
 
-func (s *Server) ping() {
-	s.readWriter.WriteString(fmt.Sprintf("PONG %s", s.ServerName))
-	s.readWriter.Flush()
+//send encodes the message and writes it to the server. An invalid message is returned as an error without
+//anything being sent
+func (s *Server) send(message *Message) error {
+	line, err := message.Encode()
+	if err != nil {
+		return err
+	}
+
+	return s.write(line)
 }
 
-func (s *Server) pass(password string) {
-	if len(password) > 1 {
-		s.readWriter.WriteString(fmt.Sprintf("PASS %s\r\n", password))
-		s.readWriter.Flush()
+//write sends complete lines to the server. Commands are sent from several go routines, the lock keeps
+//their lines from being interleaved. An error is only returned, writes also happen on the go routine that
+//reads errChan, and a broken connection is reported by recv either way
+func (s *Server) write(line string) error {
+	if s.encode != nil {
+		line = s.encode(line)
 	}
+
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	_, err := s.readWriter.WriteString(line)
+	if err == nil {
+		err = s.readWriter.Flush()
+	}
+
+	return err
 }
 
-func (s *Server) user(username string) {
-	s.readWriter.WriteString(fmt.Sprintf("NICK %s\r\n", username))
-	s.readWriter.WriteString(fmt.Sprintf("USER %s %d * :GoIRC bot\r\n", username, 0))
-	s.readWriter.Flush()
+func (s *Server) ping() error {
+	return s.send(NewMessage("PONG", s.ServerName))
 }
 
-func (s *Server) join(room ...string) {
-	if len(room) < 1 {
-		return
+//sub is the CAP subcommand, e.g. LS, REQ or END
+func (s *Server) capability(sub string, caps ...string) error {
+	message := NewMessage("CAP").Param(strings.Fields(sub)...)
+	if len(caps) > 0 {
+		message.Text(strings.Join(caps, " "))
 	}
 
-	rooms := strings.Join(room, ",")
+	return s.send(message)
+}
 
-	_, e := s.readWriter.WriteString(fmt.Sprintf("JOIN %s\r\n", rooms))
-	if e != nil {
-		s.errChan <- e
-		return
+func (s *Server) pass(password string) error {
+	if len(password) > 1 {
+		return s.send(NewMessage("PASS", password))
 	}
 
-	s.readWriter.Flush()
+	return nil
 }
 
-func (s *Server) part(room, message string) {
-	_, e := s.readWriter.WriteString(fmt.Sprintf("PART %s :%s\r\n", room, message))
-	if e != nil {
-		s.errChan <- e
-		return
+func (s *Server) user(username string) error {
+	if err := s.nick(username); err != nil {
+		return err
 	}
 
-	s.readWriter.Flush()
+	return s.send(NewMessage("USER", username, "0", "*").Text("GoIRC bot"))
+}
+
+func (s *Server) nick(nick string) error {
+	return s.send(NewMessage("NICK", nick))
 }
 
-func (s *Server) invite(nick, room string) {
-	_, e := s.readWriter.WriteString(fmt.Sprintf("INVITE %s %s\r\n", nick, room))
-	if e != nil {
-		s.errChan <- e
-		return
+func (s *Server) join(room ...string) error {
+	return s.joinChannels(joinArgs(room))
+}
+
+func (s *Server) joinChannels(channels []ChannelKey) error {
+	for _, message := range joinMessages(channels) {
+		if err := s.send(message); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//an arg that isn't a channel is the key of the channel before it, e.g. "#chan key #other"
+func joinArgs(args []string) []ChannelKey {
+	var channels []ChannelKey
+
+	for _, arg := range args {
+		if len(arg) == 0 {
+			continue
+		}
+
+		if !strings.ContainsAny(arg[:1], "#&+!") && len(channels) > 0 {
+			channels[len(channels)-1].Key = arg
+			continue
+		}
+
+		channels = append(channels, ChannelKey{Name: arg})
+	}
+
+	return channels
+}
+
+//the JOIN messages for the channels, keyed channels have to be listed first for the keys to line up
+func joinMessages(channels []ChannelKey) []*Message {
+	var keyed, keys, open []string
+	var messages []*Message
+
+	for _, channel := range channels {
+		if len(channel.Name) == 0 {
+			continue
+		}
+
+		if len(channel.Key) > 0 {
+			keyed = append(keyed, channel.Name)
+			keys = append(keys, channel.Key)
+		} else {
+			open = append(open, channel.Name)
+		}
+	}
+
+	// keep each line well short of the 512 byte limit
+	rooms := append(keyed, open...)
+	for len(rooms) > 0 {
+		count, length := 0, 0
+		for count < len(rooms) && length+len(rooms[count]) < 200 {
+			if count < len(keys) {
+				length += len(keys[count]) + 1
+			}
+			length += len(rooms[count]) + 1
+			count++
+		}
+		if count == 0 {
+			count = 1
+		}
+
+		message := NewMessage("JOIN", strings.Join(rooms[:count], ","))
+		if len(keys) > 0 {
+			end := count
+			if end > len(keys) {
+				end = len(keys)
+			}
+			message.Param(strings.Join(keys[:end], ","))
+			keys = keys[end:]
+		}
+
+		messages = append(messages, message)
+		rooms = rooms[count:]
 	}
 
-	s.readWriter.Flush()
+	return messages
+}
+
+//modes may hold the mode args too, e.g. "+o nick"
+func (s *Server) mode(target, modes string) error {
+	return s.send(NewMessage("MODE", target).Param(strings.Fields(modes)...))
+}
+
+func (s *Server) oper(name, password string) error {
+	return s.send(NewMessage("OPER", name, password))
 }
 
-func (s *Server) kick(user, room, message string) {
-	_, e := s.readWriter.WriteString(fmt.Sprintf("KICK %s %s :%s\r\n", room, user, message))
-	if e != nil {
-		s.errChan <- e
-		return
+func (s *Server) topic(room, topic string) error {
+	return s.send(NewMessage("TOPIC", room).Text(topic))
+}
+
+func (s *Server) privMessage(target, message string) error {
+	return s.send(NewMessage("PRIVMSG", target).Text(message))
+}
+
+func (s *Server) labeledPrivMessage(label, target, message string) error {
+	return s.send(NewMessage("PRIVMSG", target).Text(message).Tag("label", label))
+}
+
+func (s *Server) who(mask, fields string) error {
+	message := NewMessage("WHO", mask)
+	if len(fields) > 0 {
+		message.Param(fields)
 	}
 
-	s.readWriter.Flush()
+	return s.send(message)
 }
 
-func (s *Server) privMessage(target, message string) {
-	_, e := s.readWriter.WriteString(fmt.Sprintf("PRIVMSG %s :%s\r\n", target, message))
-	if e != nil {
-		s.errChan <- e
+func (s *Server) ison(nicks ...string) error {
+	if len(nicks) < 1 {
+		return nil
 	}
 
-	s.readWriter.Flush()
+	return s.send(NewMessage("ISON", nicks...))
 }
 
-func (s *Server) list(scope ...string) {
-	if len(scope) < 1 {
-		return
+//op is + or - to add or remove the nicks from our MONITOR list
+func (s *Server) monitor(op string, nicks ...string) error {
+	if len(nicks) < 1 {
+		return nil
 	}
 
-	scopes := strings.Join(scope, ",")
+	return s.send(NewMessage("MONITOR", op, strings.Join(nicks, ",")))
+}
 
-	_, e := s.readWriter.WriteString(fmt.Sprintf("LIST %s\r\n", scopes))
-	if e != nil {
-		s.errChan <- e
+//an empty message marks us as back
+func (s *Server) away(message string) error {
+	if len(message) > 0 {
+		return s.send(NewMessage("AWAY").Text(message))
 	}
 
-	s.readWriter.Flush()
+	return s.send(NewMessage("AWAY"))
 }
 
-func (s *Server) name(scope ...string) {
-	if len(scope) < 1 {
-		return
+func (s *Server) chatHistory(args ...string) error {
+	return s.send(NewMessage("CHATHISTORY", args...))
+}
+
+func (s *Server) setName(realName string) error {
+	return s.send(NewMessage("SETNAME").Text(realName))
+}
+
+func (s *Server) kill(nick, reason string) error {
+	return s.send(NewMessage("KILL", nick).Text(reason))
+}
+
+func (s *Server) wallops(message string) error {
+	return s.send(NewMessage("WALLOPS").Text(message))
+}
+
+func (s *Server) saMode(target, modes string) error {
+	return s.send(NewMessage("SAMODE", target).Param(strings.Fields(modes)...))
+}
+
+func (s *Server) stats(query, server string) error {
+	message := NewMessage("STATS", query)
+	if len(server) > 0 {
+		message.Param(server)
 	}
 
-	scopes := strings.Join(scope, ",")
+	return s.send(message)
+}
 
-	_, e := s.readWriter.WriteString(fmt.Sprintf("NAMES %s\r\n", scopes))
-	if e != nil {
-		s.errChan <- e
+//kind is K, G or D. A duration of 0 minutes is a permanent ban
+func (s *Server) serverBan(kind string, minutes int, mask, reason string) error {
+	message := NewMessage(kind + "LINE")
+	if minutes > 0 {
+		message.Param(strconv.Itoa(minutes))
 	}
 
-	s.readWriter.Flush()
+	return s.send(message.Param(mask).Text(reason))
+}
+
+func (s *Server) serverUnban(kind, mask string) error {
+	return s.send(NewMessage("UN"+kind+"LINE", mask))
 }
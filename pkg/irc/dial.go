@@ -0,0 +1,341 @@
+package irc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//fallbackDelay how long a connection attempt gets before the next address of the host is tried alongside
+//it, the "happy eyeballs" delay of RFC 8305
+const fallbackDelay = 250 * time.Millisecond
+
+//maxRedirects how many RPL_BOUNCE or RPL_REDIR redirects in a row are followed
+const maxRedirects = 5
+
+//ServerAddress a server of the network, Port defaults to 6697 with TLS and 6667 without
+type ServerAddress struct {
+	Host   string
+	Port   int32
+	UseTLS bool
+}
+
+func (a ServerAddress) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(int(a.port())))
+}
+
+func (a ServerAddress) port() int32 {
+	switch {
+	case a.Port > 0:
+		return a.Port
+	case a.UseTLS:
+		return 6697
+	default:
+		return 6667
+	}
+}
+
+type dialResult struct {
+	conn net.Conn
+	ip   string
+	err  error
+}
+
+//the servers in the order they are tried, a redirect from the last server comes first and the server that
+//worked last time before the others
+func (s *Server) pool() []ServerAddress {
+	servers := s.servers
+	if len(servers) == 0 {
+		servers = []ServerAddress{{Host: s.ServerName, Port: s.Port, UseTLS: s.UseTSL}}
+	}
+
+	var ordered []ServerAddress
+	if redirect := s.takeRedirect(); redirect != nil {
+		ordered = append(ordered, *redirect)
+	}
+
+	for _, server := range servers {
+		if server == s.lastGood {
+			ordered = append(ordered, server)
+		}
+	}
+	for _, server := range servers {
+		if server != s.lastGood {
+			ordered = append(ordered, server)
+		}
+	}
+
+	return ordered
+}
+
+//dial connects to the first server of the pool that accepts the connection, and completes the TLS handshake
+//for servers that use it. The error lists why each server failed, a bind address we can't use fails before
+//any server is tried
+func (s *Server) dial(ctx context.Context) (net.Conn, error) {
+	if !validFamily(s.family) {
+		return nil, fmt.Errorf("Unknown address family %q", s.family)
+	}
+
+	binds, err := s.bindAddrs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var failures []error
+
+	for _, server := range s.pool() {
+		conn, ip, err := s.dialHost(ctx, server, binds)
+		if err == nil && server.UseTLS {
+			conn, err = s.handshake(ctx, conn, server.Host)
+		}
+		if err != nil {
+			failures = append(failures, fmt.Errorf("%s: %v", server, err))
+
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+
+		s.lastGood = server
+		s.lastIP = ip
+		s.address = server
+
+		return conn, nil
+	}
+
+	return nil, fmt.Errorf("Couldn't connect to any server: %w", errors.Join(failures...))
+}
+
+//dialHost tries every address the host resolves to, or connects through the proxy when there is one. The
+//attempts are started fallbackDelay apart, or as soon as the one before fails, alternating between IPv6 and
+//IPv4, and the first to connect is used
+func (s *Server) dialHost(ctx context.Context, server ServerAddress, binds []net.IPAddr) (net.Conn, string, error) {
+	port := strconv.Itoa(int(server.port()))
+
+	// the proxy resolves the host itself
+	if s.proxy != nil {
+		dialer := net.Dialer{
+			Timeout:   s.Timeout,
+			LocalAddr: proxyLocalAddr(binds, s.family),
+		}
+
+		conn, err := s.proxy.dial(ctx, dialer, network(s.family), server.Host, port)
+		return conn, "", err
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, server.Host)
+	if err != nil {
+		return nil, "", err
+	}
+
+	ips := interleaveFamilies(addrs, s.family, binds, s.lastIP)
+	if len(ips) == 0 {
+		return nil, "", fmt.Errorf("None of the addresses of %s match the address family or bind address", server.Host)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// buffered so attempts that finish after the winner don't block
+	results := make(chan dialResult, len(ips))
+
+	next, pending := 0, 0
+	attempt := func() {
+		ip := ips[next]
+		next++
+		pending++
+
+		dialer := net.Dialer{
+			Timeout:   s.Timeout,
+			LocalAddr: localAddr(binds, net.ParseIP(ip)),
+		}
+
+		go func() {
+			conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip, port))
+			results <- dialResult{conn: conn, ip: ip, err: err}
+		}()
+	}
+
+	var lastErr error
+	attempt()
+
+	for pending > 0 {
+		var fallback <-chan time.Time
+		if next < len(ips) {
+			fallback = time.After(fallbackDelay)
+		}
+
+		select {
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				go closeLosers(results, pending)
+				return result.conn, result.ip, nil
+			}
+
+			lastErr = result.err
+			if next < len(ips) {
+				attempt()
+			}
+
+		case <-fallback:
+			attempt()
+		}
+	}
+
+	return nil, "", lastErr
+}
+
+//close the connections of attempts that succeeded after the one we use
+func closeLosers(results chan dialResult, pending int) {
+	for ; pending > 0; pending-- {
+		if result := <-results; result.conn != nil {
+			result.conn.Close()
+		}
+	}
+}
+
+//wrap the connection in TLS, the host is checked against the certificate unless the config says otherwise
+func (s *Server) handshake(ctx context.Context, conn net.Conn, host string) (net.Conn, error) {
+	config := &tls.Config{}
+	if s.tlsConfig != nil {
+		config = s.tlsConfig.Clone()
+	}
+	if len(config.ServerName) == 0 {
+		config.ServerName = host
+	}
+
+	tlsConn := tls.Client(conn, config)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return tlsConn, nil
+}
+
+//order the addresses so the families alternate, starting with the preferred family or else the one the
+//resolver preferred. Addresses the family or bind address rule out are dropped, and the address that worked
+//last time goes first
+func interleaveFamilies(addrs []net.IPAddr, family string, binds []net.IPAddr, lastIP string) []string {
+	var allowed []net.IPAddr
+	for _, addr := range addrs {
+		if usable(family, binds, addr.IP) {
+			allowed = append(allowed, addr)
+		}
+	}
+	addrs = allowed
+
+	var first, second []string
+	firstIsV4 := len(addrs) > 0 && addrs[0].IP.To4() != nil
+	if family == PreferIPv4 || family == PreferIPv6 {
+		firstIsV4 = family == PreferIPv4
+	}
+
+	for _, addr := range addrs {
+		ip := addr.String()
+		if ip == lastIP {
+			continue
+		}
+
+		if (addr.IP.To4() != nil) == firstIsV4 {
+			first = append(first, ip)
+		} else {
+			second = append(second, ip)
+		}
+	}
+
+	var ips []string
+	for _, addr := range addrs {
+		if addr.String() == lastIP {
+			ips = append(ips, lastIP)
+			break
+		}
+	}
+
+	for index := 0; index < len(first) || index < len(second); index++ {
+		if index < len(first) {
+			ips = append(ips, first[index])
+		}
+		if index < len(second) {
+			ips = append(ips, second[index])
+		}
+	}
+
+	return ips
+}
+
+//connect to this server on the next attempt, the current connection is closed so StartConnection reconnects
+func (s *Server) redirectTo(server ServerAddress) {
+	s.redirectMu.Lock()
+	s.redirect = &server
+	s.redirectMu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		s.conn.Close()
+	}
+}
+
+func (s *Server) takeRedirect() *ServerAddress {
+	s.redirectMu.Lock()
+	defer s.redirectMu.Unlock()
+
+	redirect := s.redirect
+	s.redirect = nil
+
+	return redirect
+}
+
+func (s *Server) redirecting() bool {
+	s.redirectMu.Lock()
+	defer s.redirectMu.Unlock()
+
+	return s.redirect != nil
+}
+
+var bounceRegex = regexp.MustCompile(`(?i)^Try server ([^ ,]+),? port (\+?\d+)`)
+
+//the server we are sent to by RPL_BOUNCE, "Try server <host>, port <port>", or RPL_REDIR, "<host> <port>".
+//A port starting with + uses TLS, otherwise the TLS setting of the current server is kept
+func parseRedirect(line IncomingData, current ServerAddress) (ServerAddress, bool) {
+	var host, port string
+
+	switch {
+	case line.Code == RPL_REDIR && len(line.Params) > 2:
+		host, port = line.Params[1], line.Params[2]
+
+	case line.Code == RPL_BOUNCE && line.CodeName == "RPL_BOUNCE":
+		match := bounceRegex.FindStringSubmatch(line.Message)
+		if match == nil {
+			return ServerAddress{}, false
+		}
+		host, port = match[1], match[2]
+
+	default:
+		return ServerAddress{}, false
+	}
+
+	server := ServerAddress{Host: host, UseTLS: current.UseTLS}
+	if strings.HasPrefix(port, "+") {
+		server.UseTLS = true
+		port = port[1:]
+	}
+
+	number, err := strconv.Atoi(port)
+	if err != nil || number <= 0 || number > 65535 || len(host) == 0 {
+		return ServerAddress{}, false
+	}
+	server.Port = int32(number)
+
+	return server, true
+}
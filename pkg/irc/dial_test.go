@@ -0,0 +1,109 @@
+package irc
+
+import (
+	"net"
+	"reflect"
+	"testing"
+)
+
+func TestParseRedirect(t *testing.T) {
+	tests := []struct {
+		line    string
+		current ServerAddress
+		want    ServerAddress
+		ok      bool
+	}{
+		{":srv 005 me :Try server irc.example.net, port 6667", ServerAddress{}, ServerAddress{Host: "irc.example.net", Port: 6667}, true},
+		{":srv 005 me :Try server irc.example.net, port 6697", ServerAddress{UseTLS: true}, ServerAddress{Host: "irc.example.net", Port: 6697, UseTLS: true}, true},
+		{":srv 005 me :Try server irc.example.net port 7000", ServerAddress{}, ServerAddress{Host: "irc.example.net", Port: 7000}, true},
+		{":srv 005 me CHANTYPES=# NICKLEN=30 :are supported by this server", ServerAddress{}, ServerAddress{}, false},
+		{":srv 005 me :Try server irc.example.net, port 99999", ServerAddress{}, ServerAddress{}, false},
+		{":srv 010 me irc.example.net 6667 :Server full", ServerAddress{}, ServerAddress{Host: "irc.example.net", Port: 6667}, true},
+		{":srv 010 me irc.example.net +6697 :Use TLS", ServerAddress{}, ServerAddress{Host: "irc.example.net", Port: 6697, UseTLS: true}, true},
+		{":srv 010 me irc.example.net 6667 :Server full", ServerAddress{UseTLS: true}, ServerAddress{Host: "irc.example.net", Port: 6667, UseTLS: true}, true},
+		{":srv 010 me irc.example.net port :Bad", ServerAddress{}, ServerAddress{}, false},
+		{":srv 010 me irc.example.net", ServerAddress{}, ServerAddress{}, false},
+		{":srv 001 me :Welcome", ServerAddress{}, ServerAddress{}, false},
+	}
+
+	for _, test := range tests {
+		line, ok := parseRawInput(test.line)
+		if !ok {
+			t.Fatalf("%q didn't parse", test.line)
+		}
+
+		got, ok := parseRedirect(line, test.current)
+		if ok != test.ok || got != test.want {
+			t.Errorf("parseRedirect(%q) = %+v, %v, want %+v, %v", test.line, got, ok, test.want, test.ok)
+		}
+	}
+}
+
+func TestInterleaveFamilies(t *testing.T) {
+	addrs := func(ips ...string) []net.IPAddr {
+		var list []net.IPAddr
+		for _, ip := range ips {
+			list = append(list, net.IPAddr{IP: net.ParseIP(ip)})
+		}
+		return list
+	}
+	binds := addrs
+	mixed := addrs("2001:db8::1", "2001:db8::2", "192.0.2.1", "192.0.2.2")
+
+	tests := []struct {
+		name   string
+		addrs  []net.IPAddr
+		family string
+		binds  []net.IPAddr
+		lastIP string
+		want   []string
+	}{
+		{"resolver order alternates", mixed, FamilyAny, nil, "", []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2"}},
+		{"IPv4 resolved first", addrs("192.0.2.1", "2001:db8::1", "192.0.2.2"), FamilyAny, nil, "", []string{"192.0.2.1", "2001:db8::1", "192.0.2.2"}},
+		{"prefer IPv4", mixed, PreferIPv4, nil, "", []string{"192.0.2.1", "2001:db8::1", "192.0.2.2", "2001:db8::2"}},
+		{"prefer IPv6", addrs("192.0.2.1", "2001:db8::1"), PreferIPv6, nil, "", []string{"2001:db8::1", "192.0.2.1"}},
+		{"only IPv6", mixed, OnlyIPv6, nil, "", []string{"2001:db8::1", "2001:db8::2"}},
+		{"only IPv4", mixed, OnlyIPv4, nil, "", []string{"192.0.2.1", "192.0.2.2"}},
+		{"last good address first", mixed, FamilyAny, nil, "192.0.2.2", []string{"192.0.2.2", "2001:db8::1", "192.0.2.1", "2001:db8::2"}},
+		{"unknown last address", mixed, FamilyAny, nil, "198.51.100.1", []string{"2001:db8::1", "192.0.2.1", "2001:db8::2", "192.0.2.2"}},
+		{"IPv4 bind drops IPv6", mixed, FamilyAny, binds("10.0.0.1"), "", []string{"192.0.2.1", "192.0.2.2"}},
+		{"nothing usable", addrs("2001:db8::1"), OnlyIPv4, nil, "", nil},
+	}
+
+	for _, test := range tests {
+		got := interleaveFamilies(test.addrs, test.family, test.binds, test.lastIP)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestServerPool(t *testing.T) {
+	a := ServerAddress{Host: "a.example"}
+	b := ServerAddress{Host: "b.example", UseTLS: true}
+	c := ServerAddress{Host: "c.example", Port: 7000}
+	redirect := ServerAddress{Host: "r.example", Port: 6667}
+
+	s := NewIRCServer("fallback.example", false)
+	if got := s.pool(); !reflect.DeepEqual(got, []ServerAddress{{Host: "fallback.example", Port: 6667}}) {
+		t.Errorf("without servers: got %v", got)
+	}
+
+	s.servers = []ServerAddress{a, b, c}
+	s.lastGood = b
+	if got := s.pool(); !reflect.DeepEqual(got, []ServerAddress{b, a, c}) {
+		t.Errorf("last good first: got %v", got)
+	}
+
+	s.redirect = &redirect
+	if got := s.pool(); !reflect.DeepEqual(got, []ServerAddress{redirect, b, a, c}) {
+		t.Errorf("redirect first: got %v", got)
+	}
+	if s.redirecting() {
+		t.Error("the redirect wasn't used up")
+	}
+
+	if b.String() != "b.example:6697" || c.String() != "c.example:7000" || a.String() != "a.example:6667" {
+		t.Errorf("default ports: %s %s %s", a, b, c)
+	}
+}
@@ -0,0 +1,220 @@
+package irc
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+//Encoding a single byte character set that is converted to and from UTF-8. Bytes below 0x80 are ASCII in
+//every encoding here
+type Encoding struct {
+	Name string
+
+	high    [128]rune
+	reverse map[rune]byte
+}
+
+//Latin1 ISO-8859-1, the first 256 code points of unicode
+var Latin1 = newEncoding("ISO-8859-1", func(b int) rune { return rune(b) })
+
+//CP1252 Windows-1252, latin-1 with printable characters in place of the C1 controls
+var CP1252 = newEncoding("windows-1252", func(b int) rune {
+	if b < 0xA0 {
+		return cp1252C1[b-0x80]
+	}
+
+	return rune(b)
+})
+
+//CP1251 Windows-1251, used by Cyrillic networks
+var CP1251 = newEncoding("windows-1251", func(b int) rune { return cp1251High[b-0x80] })
+
+//the bytes 0x80-0x9F of windows-1252, the ones it doesn't define are kept as the C1 control
+var cp1252C1 = [32]rune{
+	0x20AC, 0x0081, 0x201A, 0x0192, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x02C6, 0x2030, 0x0160, 0x2039, 0x0152, 0x008D, 0x017D, 0x008F,
+	0x0090, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x02DC, 0x2122, 0x0161, 0x203A, 0x0153, 0x009D, 0x017E, 0x0178,
+}
+
+//the bytes 0x80-0xFF of windows-1251
+var cp1251High = [128]rune{
+	0x0402, 0x0403, 0x201A, 0x0453, 0x201E, 0x2026, 0x2020, 0x2021,
+	0x20AC, 0x2030, 0x0409, 0x2039, 0x040A, 0x040C, 0x040B, 0x040F,
+	0x0452, 0x2018, 0x2019, 0x201C, 0x201D, 0x2022, 0x2013, 0x2014,
+	0x0098, 0x2122, 0x0459, 0x203A, 0x045A, 0x045C, 0x045B, 0x045F,
+	0x00A0, 0x040E, 0x045E, 0x0408, 0x00A4, 0x0490, 0x00A6, 0x00A7,
+	0x0401, 0x00A9, 0x0404, 0x00AB, 0x00AC, 0x00AD, 0x00AE, 0x0407,
+	0x00B0, 0x00B1, 0x0406, 0x0456, 0x0491, 0x00B5, 0x00B6, 0x00B7,
+	0x0451, 0x2116, 0x0454, 0x00BB, 0x0458, 0x0405, 0x0455, 0x0457,
+	0x0410, 0x0411, 0x0412, 0x0413, 0x0414, 0x0415, 0x0416, 0x0417,
+	0x0418, 0x0419, 0x041A, 0x041B, 0x041C, 0x041D, 0x041E, 0x041F,
+	0x0420, 0x0421, 0x0422, 0x0423, 0x0424, 0x0425, 0x0426, 0x0427,
+	0x0428, 0x0429, 0x042A, 0x042B, 0x042C, 0x042D, 0x042E, 0x042F,
+	0x0430, 0x0431, 0x0432, 0x0433, 0x0434, 0x0435, 0x0436, 0x0437,
+	0x0438, 0x0439, 0x043A, 0x043B, 0x043C, 0x043D, 0x043E, 0x043F,
+	0x0440, 0x0441, 0x0442, 0x0443, 0x0444, 0x0445, 0x0446, 0x0447,
+	0x0448, 0x0449, 0x044A, 0x044B, 0x044C, 0x044D, 0x044E, 0x044F,
+}
+
+func newEncoding(name string, char func(b int) rune) *Encoding {
+	enc := &Encoding{
+		Name:    name,
+		reverse: make(map[rune]byte),
+	}
+
+	for b := 0x80; b <= 0xFF; b++ {
+		enc.high[b-0x80] = char(b)
+		enc.reverse[char(b)] = byte(b)
+	}
+
+	return enc
+}
+
+//EncodingByName looks up an encoding by one of its common names, e.g. latin1, cp1251 or windows-1252.
+//UTF-8 returns nil, nil is used for UTF-8 everywhere in this package
+func EncodingByName(name string) (*Encoding, bool) {
+	switch strings.ToLower(strings.Replace(name, "_", "-", -1)) {
+	case "utf-8", "utf8":
+		return nil, true
+	case "latin1", "latin-1", "iso-8859-1", "iso8859-1":
+		return Latin1, true
+	case "cp1252", "windows-1252":
+		return CP1252, true
+	case "cp1251", "windows-1251":
+		return CP1251, true
+	}
+
+	return nil, false
+}
+
+//Decode converts text in this encoding to UTF-8
+func (enc *Encoding) Decode(text string) string {
+	var decoded strings.Builder
+	decoded.Grow(len(text))
+
+	for index := 0; index < len(text); index++ {
+		if b := text[index]; b < 0x80 {
+			decoded.WriteByte(b)
+		} else {
+			decoded.WriteRune(enc.high[b-0x80])
+		}
+	}
+
+	return decoded.String()
+}
+
+//Encode converts UTF-8 text to this encoding, characters the encoding doesn't have become ?
+func (enc *Encoding) Encode(text string) string {
+	var encoded strings.Builder
+	encoded.Grow(len(text))
+
+	for _, r := range text {
+		switch b, ok := enc.reverse[r]; {
+		case r < 0x80:
+			encoded.WriteByte(byte(r))
+		case ok:
+			encoded.WriteByte(b)
+		default:
+			encoded.WriteByte('?')
+		}
+	}
+
+	return encoded.String()
+}
+
+//SetChannelEncoding sets the encoding used for a channel instead of the client's Encoding, nil removes it
+func (c *Client) SetChannelEncoding(channel string, enc *Encoding) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if enc == nil {
+		delete(c.channelEncodings, strings.ToLower(channel))
+		return
+	}
+
+	c.channelEncodings[strings.ToLower(channel)] = enc
+}
+
+//the encoding of the channel the line is about, or the client's Encoding. Nil is UTF-8
+func (c *Client) lineEncoding(line string) *Encoding {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.channelEncodings) > 0 {
+		if channel := lineChannel(line); len(channel) > 0 {
+			if enc, ok := c.channelEncodings[strings.ToLower(channel)]; ok {
+				return enc
+			}
+		}
+	}
+
+	return c.Encoding
+}
+
+//decode a line from the server. Valid UTF-8 is taken as is, anything else is decoded with the fallback
+//encoding. Tags are always UTF-8
+func (c *Client) decodeLine(raw string) string {
+	tags, line := splitTags(raw)
+	if utf8.ValidString(line) {
+		return raw
+	}
+
+	// without a fallback the invalid bytes are passed on as they are
+	enc := c.lineEncoding(line)
+	if enc == nil {
+		return raw
+	}
+
+	return tags + enc.Decode(line)
+}
+
+//encode a line we send, unless the server told us with UTF8ONLY that it only accepts UTF-8
+func (c *Client) encodeLine(raw string) string {
+	if c.isupport.Has("UTF8ONLY") {
+		return raw
+	}
+
+	tags, line := splitTags(raw)
+	if enc := c.lineEncoding(line); enc != nil {
+		return tags + enc.Encode(line)
+	}
+
+	return raw
+}
+
+//split the @tags and the space after them from the rest of the line
+func splitTags(raw string) (string, string) {
+	if !strings.HasPrefix(raw, "@") {
+		return "", raw
+	}
+
+	index := strings.Index(raw, " ")
+	if index == -1 {
+		return "", raw
+	}
+
+	return raw[:index+1], raw[index+1:]
+}
+
+//the channel a line without tags is about, it is one of the first two params, e.g. PRIVMSG #chan or
+//332 me #chan
+func lineChannel(line string) string {
+	fields := strings.SplitN(line, " ", 5)
+	if len(fields) > 0 && strings.HasPrefix(fields[0], ":") {
+		fields = fields[1:]
+	}
+
+	for index := 1; index < len(fields) && index < 3; index++ {
+		// the trailing param is text, not a target
+		param := strings.TrimRight(fields[index], "\r\n")
+		if strings.HasPrefix(param, ":") {
+			break
+		}
+		if len(param) > 1 && strings.ContainsAny(param[:1], "#&+!") {
+			return param
+		}
+	}
+
+	return ""
+}
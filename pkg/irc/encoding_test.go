@@ -0,0 +1,112 @@
+package irc
+
+import "testing"
+
+func TestEncodingRoundTrip(t *testing.T) {
+	tests := []struct {
+		enc     *Encoding
+		encoded string
+		text    string
+	}{
+		{Latin1, "caf\xe9 \xfc", "café ü"},
+		{CP1252, "\x80 \x93quoted\x94", "€ “quoted”"},
+		{CP1251, "\xcf\xf0\xe8\xe2\xe5\xf2", "Привет"},
+	}
+
+	for _, test := range tests {
+		if got := test.enc.Decode(test.encoded); got != test.text {
+			t.Errorf("%s: Decode(%q) = %q, want %q", test.enc.Name, test.encoded, got, test.text)
+		}
+		if got := test.enc.Encode(test.text); got != test.encoded {
+			t.Errorf("%s: Encode(%q) = %q, want %q", test.enc.Name, test.text, got, test.encoded)
+		}
+	}
+
+	// characters the encoding doesn't have
+	if got := Latin1.Encode("€ Привет"); got != "? ??????" {
+		t.Errorf("Latin1.Encode = %q", got)
+	}
+}
+
+func TestEncodingByName(t *testing.T) {
+	tests := []struct {
+		name string
+		want *Encoding
+		ok   bool
+	}{
+		{"UTF-8", nil, true},
+		{"latin_1", Latin1, true},
+		{"ISO-8859-1", Latin1, true},
+		{"Windows-1252", CP1252, true},
+		{"cp1251", CP1251, true},
+		{"koi8-r", nil, false},
+	}
+
+	for _, test := range tests {
+		if got, ok := EncodingByName(test.name); got != test.want || ok != test.ok {
+			t.Errorf("EncodingByName(%q) = %v, %v", test.name, got, ok)
+		}
+	}
+}
+
+func TestDecodeLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding *Encoding
+		channel  *Encoding
+		raw      string
+		want     string
+	}{
+		{"valid UTF-8 is kept", CP1251, nil, ":joe!u@h PRIVMSG #go :café", ":joe!u@h PRIVMSG #go :café"},
+		{"fallback", Latin1, nil, ":joe!u@h PRIVMSG #go :caf\xe9", ":joe!u@h PRIVMSG #go :café"},
+		{"no fallback", nil, nil, ":joe!u@h PRIVMSG #go :caf\xe9", ":joe!u@h PRIVMSG #go :caf\xe9"},
+		{"channel encoding", Latin1, CP1251, ":joe!u@h PRIVMSG #ru :\xcf\xf0\xe8", ":joe!u@h PRIVMSG #ru :При"},
+		{"tags stay as they are", Latin1, nil, "@msgid=a :joe!u@h PRIVMSG #go :\xe9", "@msgid=a :joe!u@h PRIVMSG #go :é"},
+	}
+
+	for _, test := range tests {
+		c := NewClient("me", "", "irc.example")
+		c.Encoding = test.encoding
+		if test.channel != nil {
+			c.SetChannelEncoding("#RU", test.channel)
+		}
+
+		if got := c.decodeLine(test.raw); got != test.want {
+			t.Errorf("%s: got %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestEncodeLine(t *testing.T) {
+	c := NewClient("me", "", "irc.example")
+	c.Encoding = Latin1
+
+	if got := c.encodeLine("PRIVMSG #go :café"); got != "PRIVMSG #go :caf\xe9" {
+		t.Errorf("got %q", got)
+	}
+
+	// the server only accepts UTF-8
+	c.isupport.update([]string{"UTF8ONLY"})
+	if got := c.encodeLine("PRIVMSG #go :café"); got != "PRIVMSG #go :café" {
+		t.Errorf("with UTF8ONLY got %q", got)
+	}
+}
+
+func TestLineChannel(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{":joe!u@h PRIVMSG #go :hi", "#go"},
+		{":srv 332 me &local :topic", "&local"},
+		{"PRIVMSG #go :hi", "#go"},
+		{":joe!u@h PRIVMSG me :#notachannel", ""},
+		{":srv 001 me :Welcome", ""},
+	}
+
+	for _, test := range tests {
+		if got := lineChannel(test.line); got != test.want {
+			t.Errorf("lineChannel(%q) = %q, want %q", test.line, got, test.want)
+		}
+	}
+}
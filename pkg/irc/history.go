@@ -0,0 +1,271 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//the timestamp format CHATHISTORY uses for selectors
+const historyTimeFormat = "2006-01-02T15:04:05.000Z"
+
+//HistoryMessage a PRIVMSG or NOTICE returned by CHATHISTORY
+type HistoryMessage struct {
+	Command    string
+	Nick       string
+	User       string
+	Host       string
+	Target     string
+	Message    string
+	MsgID      string
+	Time       time.Time
+	ServerTime bool
+}
+
+//HistoryTarget a conversation returned by CHATHISTORY TARGETS along with the time of its latest message
+type HistoryTarget struct {
+	Target string
+	Time   time.Time
+}
+
+//HistorySelector picks a point in the history by msgid or timestamp. The zero value is "*"
+type HistorySelector struct {
+	MsgID string
+	Time  time.Time
+}
+
+//HistoryMsgID selects the message with the msgid
+func HistoryMsgID(msgid string) HistorySelector {
+	return HistorySelector{MsgID: msgid}
+}
+
+//HistoryTime selects the point in history at the time
+func HistoryTime(t time.Time) HistorySelector {
+	return HistorySelector{Time: t}
+}
+
+func (sel HistorySelector) String() string {
+	if len(sel.MsgID) > 0 {
+		return "msgid=" + sel.MsgID
+	}
+	if !sel.Time.IsZero() {
+		return "timestamp=" + sel.Time.UTC().Format(historyTimeFormat)
+	}
+
+	return "*"
+}
+
+//HistoryLatest the latest messages in target, only ones after sel if it isn't the zero value
+func (c *Client) HistoryLatest(ctx context.Context, target string, sel HistorySelector, limit int) ([]HistoryMessage, error) {
+	return c.chatHistory(ctx, "LATEST", target, []string{sel.String()}, limit)
+}
+
+//HistoryBefore the messages in target before sel
+func (c *Client) HistoryBefore(ctx context.Context, target string, sel HistorySelector, limit int) ([]HistoryMessage, error) {
+	return c.chatHistory(ctx, "BEFORE", target, []string{sel.String()}, limit)
+}
+
+//HistoryAfter the messages in target after sel
+func (c *Client) HistoryAfter(ctx context.Context, target string, sel HistorySelector, limit int) ([]HistoryMessage, error) {
+	return c.chatHistory(ctx, "AFTER", target, []string{sel.String()}, limit)
+}
+
+//HistoryAround the messages in target on either side of sel
+func (c *Client) HistoryAround(ctx context.Context, target string, sel HistorySelector, limit int) ([]HistoryMessage, error) {
+	return c.chatHistory(ctx, "AROUND", target, []string{sel.String()}, limit)
+}
+
+//HistoryBetween the messages in target between start and end
+func (c *Client) HistoryBetween(ctx context.Context, target string, start, end HistorySelector, limit int) ([]HistoryMessage, error) {
+	return c.chatHistory(ctx, "BETWEEN", target, []string{start.String(), end.String()}, limit)
+}
+
+//HistoryTargets the channels and users we had conversations with between start and end
+func (c *Client) HistoryTargets(ctx context.Context, start, end time.Time, limit int) ([]HistoryTarget, error) {
+	batch, err := c.requestHistory(ctx, "TARGETS", "", []string{HistoryTime(start).String(), HistoryTime(end).String()}, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []HistoryTarget
+	for _, line := range batch.Messages {
+		// CHATHISTORY TARGETS <target> <timestamp>
+		if line.Code != RPL_CHATHISTORY || len(line.Params) < 3 {
+			continue
+		}
+
+		target := HistoryTarget{Target: line.Params[1]}
+		target.Time, _ = time.Parse(time.RFC3339Nano, strings.TrimPrefix(line.Params[2], "timestamp="))
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+func (c *Client) chatHistory(ctx context.Context, sub, target string, selectors []string, limit int) ([]HistoryMessage, error) {
+	batch, err := c.requestHistory(ctx, sub, target, selectors, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []HistoryMessage
+	for _, line := range batch.Messages {
+		if (line.Code != RPL_PRIVMSG && line.Code != RPL_NOTICE) || len(line.Params) < 2 {
+			continue
+		}
+
+		command := "PRIVMSG"
+		if line.Code == RPL_NOTICE {
+			command = "NOTICE"
+		}
+
+		messages = append(messages, HistoryMessage{
+			Command:    command,
+			Nick:       line.Nick,
+			User:       line.User,
+			Host:       line.Host,
+			Target:     line.Params[0],
+			Message:    line.Params[1],
+			MsgID:      line.Tags["msgid"],
+			Time:       line.Time,
+			ServerTime: line.ServerTime,
+		})
+	}
+
+	return messages, nil
+}
+
+//send the CHATHISTORY command and wait for the batch with the result. This must not be called from an event callback
+func (c *Client) requestHistory(ctx context.Context, sub, target string, selectors []string, limit int) (*BatchEvent, error) {
+	if !c.server.isRunning() {
+		return nil, errors.New("Not connected to a server")
+	}
+	if !c.HasCapability("draft/chathistory") && !c.HasCapability("chathistory") {
+		return nil, errors.New("The server doesn't support CHATHISTORY")
+	}
+
+	value, _ := c.isupport.Get("CHATHISTORY")
+	if max, err := strconv.Atoi(value); err == nil && max > 0 && (limit <= 0 || limit > max) {
+		limit = max
+	}
+	if limit <= 0 {
+		limit = 50
+	}
+
+	// the batch only tells us the target, so one request at a time keeps the replies apart
+	c.historyMu.Lock()
+	defer c.historyMu.Unlock()
+
+	type historyResult struct {
+		batch *BatchEvent
+		err   error
+	}
+	result := make(chan historyResult, 1)
+
+	remove := c.addHook(func(line IncomingData) bool {
+		switch line.Code {
+		case RPL_BATCH:
+			if !line.Batch.isHistory() {
+				return false
+			}
+			if sub != "TARGETS" && !strings.EqualFold(line.Room, target) {
+				return false
+			}
+
+			result <- historyResult{batch: line.Batch}
+			return true
+
+		case RPL_FAIL:
+			if len(line.Params) > 1 && strings.EqualFold(line.Params[0], "CHATHISTORY") {
+				result <- historyResult{err: errors.New(strings.Join(line.Params[1:], " "))}
+				return true
+			}
+		}
+
+		return false
+	})
+
+	args := []string{sub}
+	if len(target) > 0 {
+		args = append(args, target)
+	}
+	args = append(args, selectors...)
+	args = append(args, strconv.Itoa(limit))
+	if err := c.server.chatHistory(args...); err != nil {
+		remove()
+		return nil, err
+	}
+
+	select {
+	case res := <-result:
+		return res.batch, res.err
+
+	case <-ctx.Done():
+		remove()
+		return nil, ctx.Err()
+	}
+}
+
+//HistoryIterator pages backwards through the history of a target, starting at the latest message
+type HistoryIterator struct {
+	client *Client
+	target string
+	limit  int
+	before HistorySelector
+	done   bool
+}
+
+//HistoryPages returns an iterator that pages backwards through the history of target, limit messages at a time
+func (c *Client) HistoryPages(target string, limit int) *HistoryIterator {
+	return &HistoryIterator{
+		client: c,
+		target: target,
+		limit:  limit,
+	}
+}
+
+//Next returns the next, older, page of messages in the order they were sent. An empty page means
+//the start of the history was reached
+func (it *HistoryIterator) Next(ctx context.Context) ([]HistoryMessage, error) {
+	if it.done {
+		return nil, nil
+	}
+
+	var page []HistoryMessage
+	var err error
+
+	if it.before == (HistorySelector{}) {
+		page, err = it.client.HistoryLatest(ctx, it.target, HistorySelector{}, it.limit)
+	} else {
+		page, err = it.client.HistoryBefore(ctx, it.target, it.before, it.limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(page) == 0 {
+		it.done = true
+		return page, nil
+	}
+
+	// without a msgid or server-time there is nothing to page from, the local arrival time would ask for
+	// the same page again
+	oldest := page[0]
+	switch {
+	case len(oldest.MsgID) > 0:
+		it.before = HistorySelector{MsgID: oldest.MsgID}
+	case oldest.ServerTime:
+		it.before = HistorySelector{Time: oldest.Time}
+	default:
+		it.done = true
+	}
+
+	return page, nil
+}
+
+//Done returns true once the start of the history was reached
+func (it *HistoryIterator) Done() bool {
+	return it.done
+}
@@ -0,0 +1,154 @@
+package irc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistorySelectorString(t *testing.T) {
+	at := time.Date(2019, 1, 2, 3, 4, 5, 6000000, time.FixedZone("x", 3600))
+
+	tests := []struct {
+		sel  HistorySelector
+		want string
+	}{
+		{HistorySelector{}, "*"},
+		{HistoryMsgID("abc"), "msgid=abc"},
+		{HistoryTime(at), "timestamp=2019-01-02T02:04:05.006Z"},
+		{HistorySelector{MsgID: "abc", Time: at}, "msgid=abc"},
+	}
+
+	for _, test := range tests {
+		if got := test.sel.String(); got != test.want {
+			t.Errorf("%+v.String() = %q, want %q", test.sel, got, test.want)
+		}
+	}
+}
+
+func TestHistoryIterator(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.negotiate("batch server-time message-tags draft/chathistory")
+	server.register("me")
+
+	it := c.HistoryPages("#go", 2)
+	// next asks for the next page, the server's answer is sent before the page is read
+	next := func() chan []HistoryMessage {
+		pages := make(chan []HistoryMessage, 1)
+		go func() {
+			page, err := it.Next(context.Background())
+			if err != nil {
+				t.Error(err)
+			}
+			pages <- page
+		}()
+
+		return pages
+	}
+	read := func(pages chan []HistoryMessage) []HistoryMessage {
+		select {
+		case page := <-pages:
+			return page
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for the page")
+		}
+		return nil
+	}
+	texts := func(page []HistoryMessage) string {
+		var texts []string
+		for _, message := range page {
+			texts = append(texts, message.Message)
+		}
+		return strings.Join(texts, " ")
+	}
+
+	pages := []struct {
+		request string
+		lines   []string
+		want    string
+	}{
+		{
+			"CHATHISTORY LATEST #go * 2",
+			[]string{
+				"@batch=h1;msgid=m3 :joe!u@h PRIVMSG #go :three",
+				"@batch=h1;msgid=m4 :joe!u@h PRIVMSG #go :four",
+			},
+			"three four",
+		},
+		{
+			// the oldest message has no msgid, its server-time is used
+			"CHATHISTORY BEFORE #go msgid=m3 2",
+			[]string{
+				"@batch=h2;time=2019-01-01T00:00:01.000Z :joe!u@h PRIVMSG #go :one",
+				"@batch=h2;time=2019-01-01T00:00:02.000Z :joe!u@h PRIVMSG #go :two",
+			},
+			"one two",
+		},
+		{"CHATHISTORY BEFORE #go timestamp=2019-01-01T00:00:01.000Z 2", nil, ""},
+	}
+
+	for index, page := range pages {
+		ref := "h" + strconv.Itoa(index+1)
+
+		got := next()
+		server.expect(page.request)
+		server.send(":srv BATCH +" + ref + " chathistory #go")
+		server.send(page.lines...)
+		server.send(":srv BATCH -" + ref)
+
+		if messages := read(got); texts(messages) != page.want {
+			t.Errorf("page %d is %q, want %q", index+1, texts(messages), page.want)
+		}
+	}
+
+	if !it.Done() {
+		t.Error("the empty page didn't end the history")
+	}
+	if page := read(next()); page != nil {
+		t.Errorf("paged past the start of the history: %v", page)
+	}
+}
+
+func TestHistoryIteratorWithoutIDs(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	// without server-time or msgids there is nothing to ask for the page before
+	server.accept()
+	server.negotiate("batch draft/chathistory")
+	server.register("me")
+
+	it := c.HistoryPages("#go", 10)
+	done := make(chan error, 1)
+	go func() {
+		_, err := it.Next(context.Background())
+		done <- err
+	}()
+
+	server.expect("CHATHISTORY LATEST #go * 10")
+	server.send(":srv BATCH +h chathistory #go", "@batch=h :joe!u@h PRIVMSG #go :hi", ":srv BATCH -h")
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for the page")
+	}
+
+	if !it.Done() {
+		t.Error("the iterator would ask for the same page again")
+	}
+}
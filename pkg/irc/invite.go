@@ -0,0 +1,78 @@
+package irc
+
+import (
+	"strings"
+)
+
+//InvitePolicy the inviters we trust enough to join a channel for, by nick or by services account
+type InvitePolicy struct {
+	Nicks    []string
+	Accounts []string
+}
+
+//allows returns true if the inviter is in the allow list
+func (p *InvitePolicy) allows(nick, account string) bool {
+	for _, allowed := range p.Nicks {
+		if strings.EqualFold(allowed, nick) {
+			return true
+		}
+	}
+
+	if len(account) == 0 {
+		return false
+	}
+
+	for _, allowed := range p.Accounts {
+		if strings.EqualFold(allowed, account) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//handle INVITE and RPL_INVITING. With invite-notify we also see invites to other users for channels we are
+//an op in, those are never auto joined
+func (c *Client) handleInvite(line IncomingData) {
+	event := EventType{
+		Server:     line.ServerName,
+		Code:       line.Code,
+		Room:       line.Room,
+		Time:       line.Time,
+		ServerTime: line.ServerTime,
+	}
+
+	switch line.Code {
+	case RPL_INVITE:
+		// :<inviter> INVITE <nick> <channel>
+		if len(line.Params) < 2 {
+			return
+		}
+
+		event.Nick = line.Nick
+		event.Target = line.Params[0]
+		event.Account = line.Account
+		if len(event.Account) == 0 {
+			if user, ok := c.User(line.Nick); ok {
+				event.Account = user.Account
+			}
+		}
+
+		if c.AutoJoinInvites != nil && strings.EqualFold(event.Target, c.Nick()) && c.AutoJoinInvites.allows(event.Nick, event.Account) {
+			c.server.join(event.Room)
+		}
+
+	case RPL_INVITING:
+		// <me> <nick> <channel>
+		if len(line.Params) < 3 {
+			return
+		}
+
+		event.Nick = c.Nick()
+		event.Target = line.Params[1]
+	}
+
+	if callback, ok := c.callbackHandlers[EventInvite]; ok {
+		callback(event)
+	}
+}
@@ -0,0 +1,73 @@
+package irc
+
+import "testing"
+
+func TestInvite(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+	c.AutoJoinInvites = &InvitePolicy{Nicks: []string{"joe"}, Accounts: []string{"annsacct"}}
+
+	events := make(chan EventType, 5)
+	c.HandleEventFunc(EventInvite, func(event EventType) {
+		events <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.negotiate("invite-notify account-tag")
+	server.register("me")
+
+	tests := []struct {
+		line     string
+		nick     string
+		target   string
+		room     string
+		wantJoin bool
+	}{
+		{":bob!u@h INVITE me #bob", "bob", "me", "#bob", false},
+		// invite-notify, joe invited someone else
+		{":joe!u@h INVITE bob #ops", "joe", "bob", "#ops", false},
+		{":srv 341 me bob #ops", "me", "bob", "#ops", false},
+		{"@account=annsacct :ann!u@h INVITE me #ann", "ann", "me", "#ann", true},
+		{":JOE!u@h INVITE me #go", "JOE", "me", "#go", true},
+	}
+
+	for _, test := range tests {
+		server.send(test.line)
+
+		event := <-events
+		if event.Nick != test.nick || event.Target != test.target || event.Room != test.room {
+			t.Errorf("%s: got the invite %s %s %s", test.line, event.Nick, event.Target, event.Room)
+		}
+
+		// the lines without a join are followed by one that joins, the next JOIN has to be for this one
+		if test.wantJoin {
+			if line := server.expect("JOIN"); line != "JOIN "+test.room {
+				t.Errorf("%s: sent %q", test.line, line)
+			}
+		}
+	}
+}
+
+func TestInvitePolicy(t *testing.T) {
+	policy := InvitePolicy{Nicks: []string{"joe"}, Accounts: []string{"annsacct"}}
+
+	tests := []struct {
+		nick, account string
+		want          bool
+	}{
+		{"joe", "", true},
+		{"Joe", "other", true},
+		{"ann", "AnnsAcct", true},
+		{"ann", "", false},
+		{"bob", "bobsacct", false},
+	}
+
+	for _, test := range tests {
+		if got := policy.allows(test.nick, test.account); got != test.want {
+			t.Errorf("allows(%q, %q) = %v, want %v", test.nick, test.account, got, test.want)
+		}
+	}
+}
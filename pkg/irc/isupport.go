@@ -0,0 +1,105 @@
+package irc
+
+import (
+	"strings"
+	"sync"
+)
+
+//ISupport the features the server advertised with RPL_ISUPPORT (005)
+type ISupport struct {
+	mu     sync.RWMutex
+	tokens map[string]string
+}
+
+func newISupport() *ISupport {
+	return &ISupport{
+		tokens: make(map[string]string),
+	}
+}
+
+//Get returns the value of the token and whether the server advertised it
+func (i *ISupport) Get(token string) (string, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	value, ok := i.tokens[strings.ToUpper(token)]
+	return value, ok
+}
+
+//Has returns true if the server advertised the token
+func (i *ISupport) Has(token string) bool {
+	_, ok := i.Get(token)
+	return ok
+}
+
+//update adds the tokens from a RPL_ISUPPORT line, e.g. NETWORK=Libera.Chat, WHOX or -EXCEPTS
+func (i *ISupport) update(tokens []string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for _, token := range tokens {
+		if len(token) == 0 {
+			continue
+		}
+
+		if token[0] == '-' {
+			delete(i.tokens, strings.ToUpper(token[1:]))
+			continue
+		}
+
+		value := ""
+		if index := strings.Index(token, "="); index != -1 {
+			value = unescapeISupport(token[index+1:])
+			token = token[:index]
+		}
+
+		i.tokens[strings.ToUpper(token)] = value
+	}
+}
+
+//reset forgets everything the server advertised, used when the connection is closed
+func (i *ISupport) reset() {
+	i.mu.Lock()
+	i.tokens = make(map[string]string)
+	i.mu.Unlock()
+}
+
+//values may escape bytes as \xHH, e.g. NETWORK=Example\x20Network
+func unescapeISupport(value string) string {
+	if !strings.Contains(value, "\\x") {
+		return value
+	}
+
+	var unescaped strings.Builder
+	for index := 0; index < len(value); index++ {
+		if value[index] == '\\' && index+3 < len(value) && value[index+1] == 'x' {
+			if b, ok := hexByte(value[index+2 : index+4]); ok {
+				unescaped.WriteByte(b)
+				index += 3
+				continue
+			}
+		}
+		unescaped.WriteByte(value[index])
+	}
+
+	return unescaped.String()
+}
+
+func hexByte(hex string) (byte, bool) {
+	var b byte
+	for _, r := range hex {
+		b <<= 4
+		switch {
+		case r >= '0' && r <= '9':
+			b |= byte(r - '0')
+		case r >= 'a' && r <= 'f':
+			b |= byte(r-'a') + 10
+		case r >= 'A' && r <= 'F':
+			b |= byte(r-'A') + 10
+		default:
+			return 0, false
+		}
+	}
+
+	return b, true
+}
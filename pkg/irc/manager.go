@@ -0,0 +1,205 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//NetworkConfig the settings of one network run by a Manager
+type NetworkConfig struct {
+	//Name identifies the network in the Manager and in the Network field of its events
+	Name string
+	//Server, Port and UseTLS are the first server tried, followed by Servers
+	Server   string
+	Port     int32
+	UseTLS   bool
+	Servers  []ServerAddress
+	Nick     string
+	Password string
+	//Setup is called with the new client to set anything else, e.g. AutoJoin or OnRegister
+	Setup func(c *Client)
+}
+
+//Manager runs a Client for each of several networks at the same time and passes their events to shared
+//handlers, with the Network field of the event set to the network it came from
+type Manager struct {
+	mu       sync.Mutex
+	clients  map[string]*Client
+	names    []string
+	handlers map[string]EventCallback
+	done     map[string]chan struct{}
+	cancels  map[string]context.CancelFunc
+	wg       sync.WaitGroup
+	//callbackMu lets the shared handlers run one at a time
+	callbackMu sync.Mutex
+}
+
+//NewManager a manager without any networks
+func NewManager() *Manager {
+	return &Manager{
+		clients:  make(map[string]*Client),
+		handlers: make(map[string]EventCallback),
+		done:     make(map[string]chan struct{}),
+		cancels:  make(map[string]context.CancelFunc),
+	}
+}
+
+//AddNetwork creates the client for the network, it connects once Run is called
+func (m *Manager) AddNetwork(config NetworkConfig) (*Client, error) {
+	if len(config.Name) == 0 {
+		return nil, errors.New("A network name is required")
+	}
+
+	var servers []ServerAddress
+	if len(config.Server) > 0 {
+		servers = append(servers, ServerAddress{Host: config.Server, Port: config.Port, UseTLS: config.UseTLS})
+	}
+	servers = append(servers, config.Servers...)
+
+	if len(servers) == 0 || len(config.Nick) == 0 {
+		return nil, fmt.Errorf("Network %s needs a server and a nick", config.Name)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := strings.ToLower(config.Name)
+	if _, ok := m.clients[key]; ok {
+		return nil, fmt.Errorf("Network %s was already added", config.Name)
+	}
+
+	client := NewClient(config.Nick, config.Password, servers[0].Host)
+	client.Servers = servers
+	if config.Setup != nil {
+		config.Setup(client)
+	}
+
+	for event, callback := range m.handlers {
+		client.HandleEventFunc(event, m.networkCallback(config.Name, callback))
+	}
+
+	m.clients[key] = client
+	m.names = append(m.names, config.Name)
+
+	return client, nil
+}
+
+//Client the client of the network
+func (m *Manager) Client(network string) (*Client, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	client, ok := m.clients[strings.ToLower(network)]
+	return client, ok
+}
+
+//Networks the names of the networks in the order they were added
+func (m *Manager) Networks() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]string{}, m.names...)
+}
+
+//HandleEventFunc sets the callback for the event on every network, including ones added later. Like the
+//client's own handlers these should be set before Run. Every network calls them from its own go routine, so
+//the Manager calls them one at a time, a handler that blocks holds up the events of all networks
+func (m *Manager) HandleEventFunc(event string, cb EventCallback) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.handlers[event] = cb
+	for _, name := range m.names {
+		m.clients[strings.ToLower(name)].HandleEventFunc(event, m.networkCallback(name, cb))
+	}
+}
+
+//Run connects every network that isn't running yet and blocks until all of them are disconnected
+func (m *Manager) Run() {
+	m.mu.Lock()
+	for _, name := range m.names {
+		key := strings.ToLower(name)
+		if _, running := m.done[key]; running {
+			continue
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+		m.done[key] = done
+		m.cancels[key] = cancel
+		client := m.clients[key]
+
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			defer close(done)
+			defer m.finished(key, done)
+
+			client.StartConnectionContext(ctx)
+		}()
+	}
+	m.mu.Unlock()
+
+	m.wg.Wait()
+}
+
+//the network's connection ended, Run may connect it again
+func (m *Manager) finished(key string, done chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.done[key] == done {
+		m.cancels[key]()
+		delete(m.done, key)
+		delete(m.cancels, key)
+	}
+}
+
+//Shutdown sends QUIT with the message to every network and waits for the servers to close the connections.
+//Networks that are still connected, or still connecting, when the ctx is done are disconnected without
+//waiting. It must not be called from an event handler
+func (m *Manager) Shutdown(ctx context.Context, message string) error {
+	m.mu.Lock()
+	running := make(map[string]chan struct{})
+	cancels := make(map[string]context.CancelFunc)
+	for key, done := range m.done {
+		running[key] = done
+		cancels[key] = m.cancels[key]
+	}
+	m.mu.Unlock()
+
+	for key := range running {
+		if client, ok := m.Client(key); ok {
+			client.Quit(message)
+		}
+	}
+
+	var err error
+	for key, done := range running {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			err = ctx.Err()
+
+			cancels[key]()
+			<-done
+		}
+	}
+
+	return err
+}
+
+//tag the events with the network they came from
+func (m *Manager) networkCallback(network string, cb EventCallback) EventCallback {
+	return func(event EventType) {
+		event.Network = network
+
+		m.callbackMu.Lock()
+		defer m.callbackMu.Unlock()
+
+		cb(event)
+	}
+}
@@ -0,0 +1,141 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestManagerEvents(t *testing.T) {
+	first, second := newTestServer(t), newTestServer(t)
+
+	manager := NewManager()
+	messages := make(chan EventType, 10)
+	manager.HandleEventFunc(EventMessage, func(event EventType) {
+		messages <- event
+	})
+
+	for name, server := range map[string]*testServer{"First": first, "Second": second} {
+		address := server.address()
+		_, err := manager.AddNetwork(NetworkConfig{
+			Name:   name,
+			Server: address.Host,
+			Port:   address.Port,
+			Nick:   "me",
+			Setup:  func(c *Client) { c.PresenceFreq = 0 },
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := manager.AddNetwork(NetworkConfig{Name: "first", Server: "irc.example", Nick: "me"}); err == nil {
+		t.Error("a network with the same name was added twice")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		manager.Run()
+		close(stopped)
+	}()
+
+	first.accept()
+	second.accept()
+	first.register("me")
+	second.register("me")
+	first.send(":joe!u@h PRIVMSG #first :hi")
+	second.send(":ann!u@h PRIVMSG #second :hi")
+
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-messages:
+			if want := map[string]string{"#first": "First", "#second": "Second"}[event.Room]; event.Network != want {
+				t.Errorf("message in %s came from %q, want %q", event.Room, event.Network, want)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("timed out waiting for the messages")
+		}
+	}
+
+	// only the first network answers QUIT by closing the connection
+	quit := make(chan struct{})
+	go func() {
+		defer close(quit)
+		first.expect("QUIT :bye")
+		first.conn.Close()
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if err := manager.Shutdown(ctx, "bye"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Shutdown returned %v, want the deadline of the network that ignored QUIT", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run didn't return after Shutdown")
+	}
+
+	<-quit
+	second.expect("QUIT :bye")
+
+	// both networks can be run again
+	go manager.Run()
+	first.accept()
+	second.accept()
+	first.expect("USER")
+	second.expect("USER")
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	manager.Shutdown(ctx, "bye")
+}
+
+func TestManagerShutdownWhileConnecting(t *testing.T) {
+	// a server that accepts the connection but never answers the TLS handshake
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			accepted <- conn
+		}
+	}()
+
+	manager := NewManager()
+	_, err = manager.AddNetwork(NetworkConfig{
+		Name:   "Slow",
+		Server: "127.0.0.1",
+		Port:   int32(listener.Addr().(*net.TCPAddr).Port),
+		UseTLS: true,
+		Nick:   "me",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		manager.Run()
+		close(stopped)
+	}()
+
+	conn := <-accepted
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	manager.Shutdown(ctx, "bye")
+
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Run didn't return after Shutdown")
+	}
+}
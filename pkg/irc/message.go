@@ -0,0 +1,158 @@
+package irc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+//Message an outgoing irc line. Params are middle params and may not contain spaces or start with a
+//colon, free text such as a message or a reason goes in Text which is always sent as the trailing param
+type Message struct {
+	Tags    map[string]string
+	Command string
+	Params  []string
+
+	text    string
+	hasText bool
+}
+
+//NewMessage a message for the command with its params
+func NewMessage(command string, params ...string) *Message {
+	return &Message{
+		Command: command,
+		Params:  params,
+	}
+}
+
+//Tag adds an IRCv3 message tag, an empty value sends the tag without one
+func (m *Message) Tag(key, value string) *Message {
+	if m.Tags == nil {
+		m.Tags = make(map[string]string)
+	}
+	m.Tags[key] = value
+
+	return m
+}
+
+//Param adds middle params
+func (m *Message) Param(params ...string) *Message {
+	m.Params = append(m.Params, params...)
+	return m
+}
+
+//Text sets the trailing param, it may contain spaces and be empty
+func (m *Message) Text(text string) *Message {
+	m.text = text
+	m.hasText = true
+
+	return m
+}
+
+//Encode validates the message and returns it as a line ending in CRLF. Any CR, LF or NUL is an error
+//rather than being stripped, it would otherwise let a param inject a command of its own
+func (m *Message) Encode() (string, error) {
+	var line strings.Builder
+
+	if len(m.Tags) > 0 {
+		keys := make([]string, 0, len(m.Tags))
+		for key := range m.Tags {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		line.WriteByte('@')
+		for index, key := range keys {
+			if !validTagKey(key) {
+				return "", fmt.Errorf("Invalid tag key %q", key)
+			}
+			if index > 0 {
+				line.WriteByte(';')
+			}
+
+			line.WriteString(key)
+			if value := m.Tags[key]; len(value) > 0 {
+				if strings.ContainsRune(value, 0) {
+					return "", fmt.Errorf("Tag %s contains a NUL", key)
+				}
+
+				line.WriteByte('=')
+				line.WriteString(escapeTagValue(value))
+			}
+		}
+		line.WriteByte(' ')
+	}
+
+	if !validCommand(m.Command) {
+		return "", fmt.Errorf("Invalid command %q", m.Command)
+	}
+	line.WriteString(m.Command)
+
+	for _, param := range m.Params {
+		if len(param) == 0 || param[0] == ':' || strings.ContainsAny(param, " \r\n\x00") {
+			return "", fmt.Errorf("Invalid %s param %q, only the trailing param may be empty, contain spaces or start with a colon", m.Command, param)
+		}
+
+		line.WriteByte(' ')
+		line.WriteString(param)
+	}
+
+	if m.hasText {
+		if strings.ContainsAny(m.text, "\r\n\x00") {
+			return "", errors.New("The message can't contain a line break or NUL")
+		}
+
+		line.WriteString(" :")
+		line.WriteString(m.text)
+	}
+
+	line.WriteString("\r\n")
+	return line.String(), nil
+}
+
+//a command is a word of letters or a three digit numeric
+func validCommand(command string) bool {
+	if len(command) == 0 {
+		return false
+	}
+
+	if len(command) == 3 && strings.Trim(command, "0123456789") == "" {
+		return true
+	}
+
+	for _, r := range command {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+
+	return true
+}
+
+//tag keys are an optional + for client tags, an optional vendor/ and a name of letters, digits and -
+func validTagKey(key string) bool {
+	key = strings.TrimPrefix(key, "+")
+	if len(key) == 0 {
+		return false
+	}
+
+	for _, r := range key {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') && (r < '0' || r > '9') && !strings.ContainsRune("-./", r) {
+			return false
+		}
+	}
+
+	return !strings.HasSuffix(key, "/")
+}
+
+//the reverse of unescapeTagValue
+func escapeTagValue(value string) string {
+	return strings.NewReplacer(
+		"\\", "\\\\",
+		";", "\\:",
+		" ", "\\s",
+		"\r", "\\r",
+		"\n", "\\n",
+	).Replace(value)
+}
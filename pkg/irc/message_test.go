@@ -0,0 +1,55 @@
+package irc
+
+import "testing"
+
+func TestMessageEncode(t *testing.T) {
+	tests := []struct {
+		message *Message
+		want    string
+	}{
+		{NewMessage("PRIVMSG", "#go").Text("hello world"), "PRIVMSG #go :hello world\r\n"},
+		{NewMessage("PRIVMSG", "#go").Text(""), "PRIVMSG #go :\r\n"},
+		{NewMessage("PRIVMSG", "#go").Text(":-)"), "PRIVMSG #go ::-)\r\n"},
+		{NewMessage("JOIN", "#a,#b", "key"), "JOIN #a,#b key\r\n"},
+		{NewMessage("TAGMSG", "#go").Tag("+typing", "active").Tag("label", "a b;c\\"), "@+typing=active;label=a\\sb\\:c\\\\ TAGMSG #go\r\n"},
+		{NewMessage("PRIVMSG", "#go").Tag("+draft/reply", "").Text("x"), "@+draft/reply PRIVMSG #go :x\r\n"},
+		{NewMessage("001", "me"), "001 me\r\n"},
+	}
+
+	for _, test := range tests {
+		got, err := test.message.Encode()
+		if err != nil {
+			t.Errorf("%q: %v", test.want, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestMessageEncodeInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		message *Message
+	}{
+		{"empty command", NewMessage("")},
+		{"command with a space", NewMessage("PRIV MSG")},
+		{"two digit numeric", NewMessage("01")},
+		{"empty param", NewMessage("MODE", "")},
+		{"param with a space", NewMessage("PRIVMSG", "#a b").Text("x")},
+		{"param starting with a colon", NewMessage("PRIVMSG", ":x")},
+		{"line break in the text", NewMessage("PRIVMSG", "#go").Text("hi\r\nQUIT")},
+		{"NUL in the text", NewMessage("PRIVMSG", "#go").Text("a\x00b")},
+		{"line break in a param", NewMessage("NICK", "a\nb")},
+		{"invalid tag key", NewMessage("TAGMSG", "#go").Tag("bad key", "x")},
+		{"tag key ending in a slash", NewMessage("TAGMSG", "#go").Tag("vendor/", "x")},
+		{"NUL in a tag value", NewMessage("TAGMSG", "#go").Tag("a", "\x00")},
+	}
+
+	for _, test := range tests {
+		if line, err := test.message.Encode(); err == nil {
+			t.Errorf("%s: encoded as %q, want an error", test.name, line)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package irc
+
+import (
+	"strings"
+)
+
+//the channel visibility symbols sent in RPL_NAMREPLY
+const (
+	ChannelPublic  = "="
+	ChannelSecret  = "@"
+	ChannelPrivate = "*"
+)
+
+//NamesReply the members of a channel sent in a RPL_NAMREPLY
+type NamesReply struct {
+	Channel    string
+	Visibility string
+	Members    []Member
+}
+
+//the mode letters and the prefixes they map to from the PREFIX token, e.g. (ov)@+
+func (c *Client) prefixes() (modes string, symbols string) {
+	prefix, ok := c.isupport.Get("PREFIX")
+	if !ok {
+		prefix = "(qaohv)~&@%+"
+	}
+
+	if !strings.HasPrefix(prefix, "(") {
+		return "", ""
+	}
+
+	index := strings.Index(prefix, ")")
+	if index == -1 || len(prefix)-index-1 != index-1 {
+		return "", ""
+	}
+
+	return prefix[1:index], prefix[index+1:]
+}
+
+//map each prefix to its mode letter, e.g. @+ to ov
+func prefixModes(prefix, modes, symbols string) string {
+	var letters strings.Builder
+
+	for _, symbol := range prefix {
+		if index := strings.IndexRune(symbols, symbol); index != -1 {
+			letters.WriteByte(modes[index])
+		}
+	}
+
+	return letters.String()
+}
+
+//parse a RPL_NAMREPLY: <me> <symbol> <channel> :<names>. With multi-prefix every prefix the
+//member has is sent, with userhost-in-names the names are nick!user@host
+func (c *Client) parseNames(line IncomingData) *NamesReply {
+	if line.Code != RPL_NAMREPLY || len(line.Params) < 4 {
+		return nil
+	}
+
+	names := &NamesReply{
+		Channel:    line.Params[2],
+		Visibility: line.Params[1],
+	}
+
+	modes, symbols := c.prefixes()
+	for _, name := range strings.Fields(line.Params[3]) {
+		nick := strings.TrimLeft(name, symbols)
+		member := Member{
+			Prefix: name[:len(name)-len(nick)],
+		}
+		member.Modes = prefixModes(member.Prefix, modes, symbols)
+
+		if index := strings.Index(nick, "!"); index != -1 {
+			member.Ident = nick[index+1:]
+			nick = nick[:index]
+
+			if index = strings.Index(member.Ident, "@"); index != -1 {
+				member.Host = member.Ident[index+1:]
+				member.Ident = member.Ident[:index]
+			}
+		}
+		if len(nick) == 0 {
+			continue
+		}
+
+		member.Nick = nick
+		names.Members = append(names.Members, member)
+	}
+
+	return names
+}
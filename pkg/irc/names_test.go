@@ -0,0 +1,85 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseNames(t *testing.T) {
+	member := func(prefix, modes, nick, ident, host string) Member {
+		return Member{User: User{Nick: nick, Ident: ident, Host: host}, Prefix: prefix, Modes: modes}
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		line   string
+		want   *NamesReply
+	}{
+		{
+			"plain", "",
+			":srv 353 me = #go :@joe +ann bob",
+			&NamesReply{Channel: "#go", Visibility: ChannelPublic, Members: []Member{
+				member("@", "o", "joe", "", ""), member("+", "v", "ann", "", ""), member("", "", "bob", "", ""),
+			}},
+		},
+		{
+			"multi-prefix", "",
+			":srv 353 me @ #go :~@+joe %+ann",
+			&NamesReply{Channel: "#go", Visibility: ChannelSecret, Members: []Member{
+				member("~@+", "qov", "joe", "", ""), member("%+", "hv", "ann", "", ""),
+			}},
+		},
+		{
+			"userhost-in-names", "",
+			":srv 353 me * #go :@+joe!~joe@host.example ann!ann@10.0.0.1",
+			&NamesReply{Channel: "#go", Visibility: ChannelPrivate, Members: []Member{
+				member("@+", "ov", "joe", "~joe", "host.example"), member("", "", "ann", "ann", "10.0.0.1"),
+			}},
+		},
+		{
+			// the server's PREFIX decides which symbols are prefixes
+			"custom PREFIX", "(ov)@+",
+			":srv 353 me = #go :@joe %ann",
+			&NamesReply{Channel: "#go", Visibility: ChannelPublic, Members: []Member{
+				member("@", "o", "joe", "", ""), member("", "", "%ann", "", ""),
+			}},
+		},
+		{"not a RPL_NAMREPLY", "", ":srv 366 me #go :End of /NAMES list.", nil},
+	}
+
+	for _, test := range tests {
+		c := NewClient("me", "", "irc.example")
+		if len(test.prefix) > 0 {
+			c.isupport.update([]string{"PREFIX=" + test.prefix})
+		}
+
+		line, ok := parseRawInput(test.line)
+		if !ok {
+			t.Fatalf("%s: %q didn't parse", test.name, test.line)
+		}
+
+		if got := c.parseNames(line); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestPrefixModes(t *testing.T) {
+	tests := []struct {
+		prefix string
+		want   string
+	}{
+		{"", ""},
+		{"@", "o"},
+		{"~&@%+", "qaohv"},
+		// symbols that aren't prefixes are skipped
+		{"@!", "o"},
+	}
+
+	for _, test := range tests {
+		if got := prefixModes(test.prefix, "qaohv", "~&@%+"); got != test.want {
+			t.Errorf("prefixModes(%q) = %q, want %q", test.prefix, got, test.want)
+		}
+	}
+}
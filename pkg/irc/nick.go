@@ -0,0 +1,164 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+//DefaultNickFallback appends underscores to the nick for the first three attempts, after that the end of
+//the nick is replaced with random digits. It gives up after ten attempts
+func DefaultNickFallback(nick string, attempt int) string {
+	if attempt > 10 {
+		return ""
+	}
+
+	if attempt <= 3 {
+		return nick + strings.Repeat("_", attempt)
+	}
+
+	// keep it short enough for servers with the minimum NICKLEN of 9
+	if len(nick) > 6 {
+		nick = nick[:6]
+	}
+
+	return fmt.Sprintf("%s%03d", nick, rand.Intn(1000))
+}
+
+//Nick returns the nick we currently have on the server
+func (c *Client) Nick() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.nick) == 0 {
+		return c.UserName
+	}
+
+	return c.nick
+}
+
+//SetNick changes our nick. This also becomes the nick RegainNickFreq will try to get back
+func (c *Client) SetNick(nick string) error {
+	nick = strings.TrimSpace(nick)
+	if len(nick) == 0 || strings.ContainsAny(nick, " ,*?!@:") || strings.ContainsAny(nick[:1], "#&$0123456789-") {
+		return fmt.Errorf("Invalid nick %q", nick)
+	}
+
+	c.mu.Lock()
+	c.UserName = nick
+	c.mu.Unlock()
+
+	if c.server.isRunning() {
+		return c.server.nick(nick)
+	}
+
+	return nil
+}
+
+//the next nick to try while registering, AltNicks first and then NickFallback. Empty if we ran out
+func (c *Client) nextNick() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nickAttempts++
+	if c.nickAttempts <= len(c.AltNicks) {
+		return c.AltNicks[c.nickAttempts-1]
+	}
+
+	if c.NickFallback == nil {
+		return ""
+	}
+
+	return c.NickFallback(c.UserName, c.nickAttempts-len(c.AltNicks))
+}
+
+//RPL_WELCOME is addressed to the nick the server gave us
+func (c *Client) setRegistered(nick string) {
+	c.mu.Lock()
+	c.registered = true
+	c.nick = nick
+	c.mu.Unlock()
+}
+
+//track our own nick when the server echoes a NICK change back
+func (c *Client) nickChanged(oldNick, newNick string) {
+	c.mu.Lock()
+	if strings.EqualFold(oldNick, c.nick) {
+		c.nick = newNick
+	}
+	c.mu.Unlock()
+}
+
+//the server refused a nick. While registering we move on to the next nick, otherwise it is reported
+//as an error unless it was one of our own attempts to regain the primary nick
+func (c *Client) nickRejected(line IncomingData) {
+	attempted := ""
+	if len(line.Params) > 2 {
+		attempted = line.Params[1]
+	}
+
+	c.mu.Lock()
+	registered, regaining := c.registered, c.regaining
+	if strings.EqualFold(attempted, c.UserName) {
+		c.regaining = false
+	}
+	primary := c.UserName
+	c.mu.Unlock()
+
+	err := errors.New(line.Message)
+
+	// ERR_UNAVAILRESOURCE is also used for channels, those are not ours to handle
+	isChannel := len(attempted) > 0 && strings.ContainsAny(attempted[:1], "#&")
+
+	if !registered && !isChannel {
+		if next := c.nextNick(); len(next) > 0 {
+			c.mu.Lock()
+			c.nick = next
+			c.mu.Unlock()
+
+			c.server.nick(next)
+			return
+		}
+
+		err = errors.New("No usable nick, the nick and all alternates were rejected")
+	} else if regaining && strings.EqualFold(attempted, primary) {
+		return
+	}
+
+	if callback, ok := c.callbackHandlers[EventError]; ok {
+		callback(EventType{
+			Server:  line.ServerName,
+			Nick:    attempted,
+			Code:    line.Code,
+			Message: line.Message,
+			Err:     err,
+		})
+	}
+}
+
+//periodically try to get our primary nick back while we are using an alternate
+func (c *Client) regainNick(ctx context.Context) {
+	ticker := time.NewTicker(c.RegainNickFreq)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.mu.Lock()
+			primary := c.UserName
+			regain := c.registered && !strings.EqualFold(c.nick, primary)
+			c.regaining = regain
+			c.mu.Unlock()
+
+			if regain {
+				c.server.nick(primary)
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
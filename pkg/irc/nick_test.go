@@ -0,0 +1,94 @@
+package irc
+
+import (
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestDefaultNickFallback(t *testing.T) {
+	tests := []struct {
+		nick    string
+		attempt int
+		want    string
+	}{
+		{"me", 1, "^me_$"},
+		{"me", 3, "^me___$"},
+		{"me", 4, `^me\d{3}$`},
+		// cut down to fit a NICKLEN of 9
+		{"longnickname", 5, `^longni\d{3}$`},
+		{"me", 11, "^$"},
+	}
+
+	for _, test := range tests {
+		if got := DefaultNickFallback(test.nick, test.attempt); !regexp.MustCompile(test.want).MatchString(got) {
+			t.Errorf("DefaultNickFallback(%q, %d) = %q, want %s", test.nick, test.attempt, got, test.want)
+		}
+	}
+}
+
+func TestNickInUse(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+	c.AltNicks = []string{"alt"}
+	c.RegainNickFreq = 50 * time.Millisecond
+
+	errs := make(chan EventType, 5)
+	c.HandleEventFunc(EventError, func(event EventType) {
+		errs <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.expect("NICK me")
+
+	// the alternate nicks come first, then the fallback
+	server.send(":srv 433 * me :Nickname is already in use")
+	server.expect("NICK alt")
+	server.send(":srv 433 * alt :Nickname is already in use")
+	server.expect("NICK me_")
+	server.register("me_")
+	eventually(t, "the nick the server welcomed us with", func() bool { return c.Nick() == "me_" })
+
+	// a failed regain isn't an error, the next one gets the nick
+	server.expect("NICK me")
+	server.send(":srv 433 me_ me :Nickname is already in use")
+	server.expect("NICK me")
+	server.send(":me_!u@h NICK :me")
+	eventually(t, "the regained nick", func() bool { return c.Nick() == "me" })
+
+	select {
+	case event := <-errs:
+		t.Errorf("unexpected error %q", event.Message)
+	default:
+	}
+}
+
+func TestNickRunOut(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+	c.NickFallback = nil
+
+	errs := make(chan EventType, 5)
+	c.HandleEventFunc(EventError, func(event EventType) {
+		errs <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.expect("NICK me")
+	server.send(":srv 433 * me :Nickname is already in use")
+
+	select {
+	case event := <-errs:
+		if event.Err == nil || event.Err.Error() != "No usable nick, the nick and all alternates were rejected" {
+			t.Errorf("got the error %v", event.Err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("running out of nicks wasn't reported")
+	}
+}
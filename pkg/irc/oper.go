@@ -0,0 +1,195 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+)
+
+//the kinds of server bans an operator can set
+const (
+	//BanKLine bans a user@host mask from this server
+	BanKLine = "K"
+	//BanGLine bans a user@host mask from the whole network
+	BanGLine = "G"
+	//BanDLine bans an ip or cidr range before the client even registers
+	BanDLine = "D"
+)
+
+//ServerBan a K, G or D-line. A zero Duration is a permanent ban
+type ServerBan struct {
+	Kind     string
+	Mask     string
+	Duration time.Duration
+	Reason   string
+}
+
+//StatsEntry a single reply line of a STATS query, Fields are its params without our nick
+type StatsEntry struct {
+	Code     int32
+	CodeName string
+	Fields   []string
+	Message  string
+}
+
+//Oper becomes an irc operator and waits for the server to accept or refuse it, a refusal is returned as
+//a *ReplyError
+func (c *Client) Oper(ctx context.Context, name, password string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	wait, remove := c.expect(func(line IncomingData) bool {
+		switch line.Code {
+		case RPL_YOUREOPER, ERR_PASSWDMISMATCH, ERR_NOOPERHOST, ERR_NOPRIVILEGES:
+			return true
+		case ERR_NEEDMOREPARAMS:
+			return len(line.Params) > 1 && strings.EqualFold(line.Params[1], "OPER")
+		}
+
+		return false
+	})
+	if err := c.server.oper(name, password); err != nil {
+		remove()
+		return err
+	}
+
+	line, err := wait(ctx)
+	if err != nil {
+		return err
+	}
+	if line.Code != RPL_YOUREOPER {
+		return replyError(line)
+	}
+
+	return nil
+}
+
+//Kill disconnects the user from the network
+func (c *Client) Kill(nick, reason string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	return c.server.kill(nick, reason)
+}
+
+//Wallops sends the message to every user with the +w user mode
+func (c *Client) Wallops(message string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	return c.server.wallops(message)
+}
+
+//SAMode sets modes on a channel or user through services, without needing to be an op there
+func (c *Client) SAMode(target, modes string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	return c.server.saMode(target, modes)
+}
+
+//Stats sends a STATS query, e.g. "u" for uptime or "k" for the K-lines, and collects the replies until
+//RPL_ENDOFSTATS. Only the STATS numerics are collected, 211-250 and 262, other replies that arrive in the
+//meantime, e.g. LUSERS, are left out. server is optional and asks another server on the network. This must not be called
+//from an event callback
+func (c *Client) Stats(ctx context.Context, query, server string) ([]StatsEntry, error) {
+	if !c.server.isRunning() {
+		return nil, errors.New("Not connected to a server")
+	}
+
+	// the replies don't say which query they belong to, so only one can run at a time
+	c.statsMu.Lock()
+	defer c.statsMu.Unlock()
+
+	type statsResult struct {
+		entries []StatsEntry
+		err     error
+	}
+
+	var entries []StatsEntry
+	result := make(chan statsResult, 1)
+
+	remove := c.addHook(func(line IncomingData) bool {
+		switch {
+		case line.Code == RPL_ENDOFSTATS:
+			result <- statsResult{entries: entries}
+			return true
+
+		case line.Code == ERR_NOPRIVILEGES || line.Code == ERR_NOSUCHSERVER:
+			result <- statsResult{err: errors.New(line.Message)}
+			return true
+
+		case isStatsReply(line.Code) && len(line.Params) > 0:
+			entries = append(entries, StatsEntry{
+				Code:     line.Code,
+				CodeName: line.CodeName,
+				Fields:   line.Params[1:],
+				Message:  line.Message,
+			})
+		}
+
+		return false
+	})
+
+	if err := c.server.stats(query, server); err != nil {
+		remove()
+		return nil, err
+	}
+
+	select {
+	case res := <-result:
+		return res.entries, res.err
+
+	case <-ctx.Done():
+		remove()
+		return nil, ctx.Err()
+	}
+}
+
+//the numerics a STATS query is answered with, RPL_ENDOFSTATS ends it
+func isStatsReply(code int32) bool {
+	return (code >= 211 && code <= 250 && code != RPL_ENDOFSTATS) || code == 262
+}
+
+//AddBan sets a K, G or D-line. Durations are sent in minutes before the mask, the way hybrid and
+//charybdis based servers expect them
+func (c *Client) AddBan(ban ServerBan) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	kind := strings.ToUpper(ban.Kind)
+	if kind != BanKLine && kind != BanGLine && kind != BanDLine {
+		return errors.New("Unknown ban kind " + ban.Kind)
+	}
+	if len(ban.Mask) == 0 || strings.ContainsAny(ban.Mask, " \r\n") {
+		return errors.New("Invalid ban mask")
+	}
+
+	minutes := 0
+	if ban.Duration > 0 {
+		// round up, a ban shorter than a minute would otherwise be permanent
+		minutes = int((ban.Duration + time.Minute - 1) / time.Minute)
+	}
+
+	return c.server.serverBan(kind, minutes, ban.Mask, ban.Reason)
+}
+
+//RemoveBan removes the K, G or D-line on the mask
+func (c *Client) RemoveBan(kind, mask string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	kind = strings.ToUpper(kind)
+	if kind != BanKLine && kind != BanGLine && kind != BanDLine {
+		return errors.New("Unknown ban kind " + kind)
+	}
+
+	return c.server.serverUnban(kind, mask)
+}
@@ -0,0 +1,108 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestOper(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply []string
+		code  int32
+	}{
+		{"accepted", []string{":srv 381 me :You are now an IRC operator"}, 0},
+		{"wrong password", []string{":srv 464 me :Password incorrect"}, ERR_PASSWDMISMATCH},
+		{"no o-line", []string{":srv 491 me :No O-lines for your host"}, ERR_NOOPERHOST},
+		// a 461 for another command doesn't answer OPER
+		{"missing params", []string{":srv 461 me KICK :Not enough parameters", ":srv 461 me OPER :Not enough parameters"}, ERR_NEEDMOREPARAMS},
+		{"no privileges", []string{":srv 481 me :Permission Denied"}, ERR_NOPRIVILEGES},
+	}
+
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	// the client is running once it sent USER
+	server.expect("USER")
+	server.register("me")
+
+	for _, test := range tests {
+		done := make(chan error, 1)
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			done <- c.Oper(ctx, "me", "secret")
+		}()
+
+		server.expect("OPER me secret")
+		server.send(test.reply...)
+
+		err := <-done
+		var replyErr *ReplyError
+		switch {
+		case test.code == 0 && err != nil:
+			t.Errorf("%s: %v", test.name, err)
+		case test.code != 0 && (!errors.As(err, &replyErr) || replyErr.Code != test.code):
+			t.Errorf("%s: got the error %v, want the numeric %d", test.name, err, test.code)
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	// the client is running once it sent USER
+	server.expect("USER")
+	server.register("me")
+
+	type statsResult struct {
+		entries []StatsEntry
+		err     error
+	}
+	done := make(chan statsResult, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+
+		entries, err := c.Stats(ctx, "u", "")
+		done <- statsResult{entries, err}
+	}()
+
+	server.expect("STATS u")
+	server.send(
+		":srv 251 me :There are 5 users and 0 invisible on 1 servers",
+		":srv 242 me :Server Up 2 days, 3:04:05",
+		":srv 205 me User users joe[h] 0 0",
+		":srv 250 me :Highest connection count: 7 (6 clients)",
+		":srv 219 me u :End of /STATS report",
+	)
+
+	res := <-done
+	if res.err != nil {
+		t.Fatal(res.err)
+	}
+	if len(res.entries) != 2 || res.entries[0].Code != 242 || res.entries[1].Code != 250 {
+		t.Errorf("got the entries %+v, want 242 and 250", res.entries)
+	}
+}
+
+func TestIsStatsReply(t *testing.T) {
+	tests := map[int32]bool{200: false, 211: true, 216: true, 219: false, 242: true, 250: true, 251: false, 255: false, 262: true}
+
+	for code, want := range tests {
+		if got := isStatsReply(code); got != want {
+			t.Errorf("isStatsReply(%d) = %v, want %v", code, got, want)
+		}
+	}
+}
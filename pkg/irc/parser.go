@@ -12,25 +12,58 @@ const (
 	RPL_CREATED       = 3
 	RPL_MYINFO        = 4
 	RPL_BOUNCE        = 5
+	RPL_ISUPPORT      = 5
+	RPL_REDIR         = 10
 	RPL_LUSERCLIENT   = 251
 	RPL_LUSEROP       = 252
 	RPL_LUSERUNKNOWN  = 253
 	RPL_LUSERCHANNELS = 254
 	RPL_LUSERME       = 255
+	RPL_AWAY          = 301
+	RPL_ISON          = 303
+	RPL_UNAWAY        = 305
+	RPL_NOWAWAY       = 306
+	RPL_ENDOFWHO      = 315
 	RPL_LIST          = 322
 	RPL_LISTEND       = 323
+	RPL_NOTOPIC       = 331
 	RPL_TOPIC         = 332
+	RPL_TOPICWHOTIME  = 333
+	RPL_INVITING      = 341
+	RPL_WHOREPLY      = 352
 	RPL_NAMREPLY      = 353
+	RPL_WHOSPCRPL     = 354
 	RPL_ENDOFNAMES    = 366
 	RPL_FORWARDJOIN   = 470
 	RPL_MOTDSTART     = 375
 	RPL_MOTD          = 372
 	RPL_ENDOFMOTD     = 376
+	RPL_ENDOFSTATS    = 219
+	RPL_YOUREOPER     = 381
+	RPL_MONONLINE     = 730
+	RPL_MONOFFLINE    = 731
+	RPL_MONLIST       = 732
+	RPL_ENDOFMONLIST  = 733
+	RPL_LOGGEDIN      = 900
 
-	RPL_ROOMJOIN = 199
-	RPL_ROOMPART = 198
-	RPL_ROOMQUIT = 197
-	RPL_PRIVMSG  = 196
+	RPL_ROOMJOIN    = 199
+	RPL_ROOMPART    = 198
+	RPL_ROOMQUIT    = 197
+	RPL_PRIVMSG     = 196
+	RPL_NICKCHANGE  = 195
+	RPL_CAP         = 194
+	RPL_USERAWAY    = 193
+	RPL_BATCH       = 192
+	RPL_FAIL        = 191
+	RPL_NOTICE      = 190
+	RPL_CHATHISTORY = 189
+	RPL_ACK         = 188
+	RPL_ACCOUNT     = 187
+	RPL_CHGHOST     = 186
+	RPL_SETNAME     = 185
+	RPL_INVITE      = 184
+	RPL_ROOMKICK    = 183
+	RPL_ROOMTOPIC   = 182
 )
 
 const (
@@ -40,12 +73,27 @@ const (
 	ERR_CANNOTSENDTOCHAN = 404
 	ERR_TOOMANYCHANNELS  = 405
 	ERR_WASNOSUCHNICK    = 406
+	ERR_TOOMANYTARGETS   = 407
 	ERR_NORECIPIENT      = 411
 	ERR_NOTEXTTOSEND     = 412
 	ERR_NOTOPLEVEL       = 413
 	ERR_WILDTOPLEVEL     = 414
 	ERR_BADMASK          = 415
 	RPL_ERRORJOIN        = 417
+	ERR_NOMOTD           = 422
+	ERR_NONICKNAMEGIVEN  = 431
+	ERR_ERRONEUSNICKNAME = 432
+	ERR_NICKNAMEINUSE    = 433
+	ERR_NICKCOLLISION    = 436
+	ERR_UNAVAILRESOURCE  = 437
+	ERR_INVITEONLYCHAN   = 473
+	ERR_BANNEDFROMCHAN   = 474
+	ERR_BADCHANNELKEY    = 475
+	ERR_NEEDMOREPARAMS   = 461
+	ERR_PASSWDMISMATCH   = 464
+	ERR_NOPRIVILEGES     = 481
+	ERR_NOOPERHOST       = 491
+	ERR_MONLISTFULL      = 734
 )
 
 type IncomingData struct {
@@ -53,68 +101,277 @@ type IncomingData struct {
 	CodeName   string
 	ServerName string
 	Room       string
+	Rooms      []string
+	Target     string
 	Count      int
 	Nick       string
+	User       string
+	Host       string
+	Account    string
+	RealName   string
 	Message    string
+	Params     []string
+	Tags       map[string]string
+	Batch      *BatchEvent
 	Time       time.Time
+	//ServerTime is true when Time came from the server-time tag instead of the local clock
+	ServerTime bool
 }
 
 // parse the input from the server to determine the incoming message type.
 // Return false if this couldn't be done
 func parseRawInput(line string) (IncomingData, bool) {
+	line = strings.TrimSpace(line)
 	if len(line) == 0 {
 		return IncomingData{}, false
 	}
 
-	segments := strings.Split(strings.TrimSpace(line), " ")
+	var tags map[string]string
+	if line[0] == '@' {
+		index := strings.Index(line, " ")
+		if index == -1 {
+			return IncomingData{}, false
+		}
+
+		tags = parseTags(line[1:index])
+		line = strings.TrimLeft(line[index:], " ")
+	}
+
+	segments := strings.Split(line, " ")
+	if len(segments) < 2 {
+		return IncomingData{}, false
+	}
+
+	var data IncomingData
+	var ok bool
+
 	responseCode, err := strconv.Atoi(segments[1])
 	if err != nil {
-		return parseNonNumericReply(segments)
+		data, ok = parseNonNumericReply(segments)
+	} else {
+		data, ok = parseNumericReply(responseCode, segments)
+	}
+
+	data.Tags = tags
+	if account, ok := tags["account"]; ok && len(data.Account) == 0 {
+		data.Account = account
+	}
+
+	return data, ok
+}
+
+//parse the IRCv3 message tags, e.g. time=2019-01-01T00:00:00.000Z;msgid=abc
+func parseTags(raw string) map[string]string {
+	tags := make(map[string]string)
+
+	for _, tag := range strings.Split(raw, ";") {
+		if len(tag) == 0 {
+			continue
+		}
+
+		key, value := tag, ""
+		if index := strings.Index(tag, "="); index != -1 {
+			key, value = tag[:index], unescapeTagValue(tag[index+1:])
+		}
+		tags[key] = value
+	}
+
+	return tags
+}
+
+func unescapeTagValue(value string) string {
+	if !strings.Contains(value, "\\") {
+		return value
+	}
+
+	var unescaped strings.Builder
+	for index := 0; index < len(value); index++ {
+		if value[index] != '\\' {
+			unescaped.WriteByte(value[index])
+			continue
+		}
+
+		// a trailing backslash is dropped
+		index++
+		if index == len(value) {
+			break
+		}
+
+		switch value[index] {
+		case ':':
+			unescaped.WriteByte(';')
+		case 's':
+			unescaped.WriteByte(' ')
+		case 'r':
+			unescaped.WriteByte('\r')
+		case 'n':
+			unescaped.WriteByte('\n')
+		default:
+			unescaped.WriteByte(value[index])
+		}
 	}
 
-	return parseNumericReply(responseCode, segments)
+	return unescaped.String()
 }
 
 func parseNonNumericReply(segments []string) (IncomingData, bool) {
 	data := IncomingData{}
 	data.Time = time.Now()
+	data.Params = parseParams(segments[2:])
 
 	if index := strings.Index(segments[0], "!"); index != -1 {
 		data.Nick = segments[0][1:index]
+		data.User = segments[0][index+1:]
+
+		if index = strings.Index(data.User, "@"); index != -1 {
+			data.Host = data.User[index+1:]
+			data.User = data.User[:index]
+		}
 	}
 
 	switch strings.ToLower(segments[1]) {
 	case "join":
 		data.Code = RPL_ROOMJOIN
 		data.CodeName = "RPL_ROOMJOIN"
-		data.Room = segments[2]
+		data.Room = strings.TrimPrefix(segments[2], ":")
+
+		// extended-join: JOIN <channel> <account> :<realname>
+		if len(data.Params) > 2 {
+			if data.Params[1] != "*" {
+				data.Account = data.Params[1]
+			}
+			data.RealName = data.Params[2]
+		}
 	case "part":
 		data.Code = RPL_ROOMPART
 		data.CodeName = "RPL_ROOMPART"
-		data.Room = segments[2]
+		data.Room = strings.TrimPrefix(segments[2], ":")
 	case "quit":
 		data.Code = RPL_ROOMQUIT
 		data.CodeName = "RPL_ROOMQUIT"
-		data.Message = strings.Join(segments[2:], " ")
+		if len(data.Params) > 0 {
+			data.Message = data.Params[0]
+		}
+	case "kick":
+		// KICK <channel> <nick> [:<reason>]
+		data.Code = RPL_ROOMKICK
+		data.CodeName = "RPL_ROOMKICK"
+		if len(data.Params) > 1 {
+			data.Room = data.Params[0]
+			data.Target = data.Params[1]
+		}
+		if len(data.Params) > 2 {
+			data.Message = data.Params[2]
+		}
+	case "topic":
+		data.Code = RPL_ROOMTOPIC
+		data.CodeName = "RPL_ROOMTOPIC"
+		if len(data.Params) > 1 {
+			data.Room = data.Params[0]
+			data.Message = data.Params[1]
+		}
 	case "privmsg":
 		data.Code = RPL_PRIVMSG
 		data.CodeName = "RPL_PRIVMSG"
 		data.Room = segments[2]
 		data.Message = strings.Join(segments[3:], " ")
+	case "nick":
+		data.Code = RPL_NICKCHANGE
+		data.CodeName = "RPL_NICKCHANGE"
+		data.Message = strings.TrimPrefix(segments[2], ":")
+		data.Target = data.Message
+	case "cap":
+		data.Code = RPL_CAP
+		data.CodeName = "RPL_CAP"
+		data.ServerName = strings.TrimPrefix(segments[0], ":")
+	case "notice":
+		data.Code = RPL_NOTICE
+		data.CodeName = "RPL_NOTICE"
+		if len(data.Nick) == 0 {
+			data.ServerName = strings.TrimPrefix(segments[0], ":")
+		}
+		if len(data.Params) > 1 {
+			data.Room = data.Params[0]
+			data.Message = data.Params[1]
+		}
+	case "fail":
+		data.Code = RPL_FAIL
+		data.CodeName = "RPL_FAIL"
+		data.ServerName = strings.TrimPrefix(segments[0], ":")
+		if len(data.Params) > 0 {
+			data.Message = data.Params[len(data.Params)-1]
+		}
+	case "account":
+		// an account of * means the user logged out
+		data.Code = RPL_ACCOUNT
+		data.CodeName = "RPL_ACCOUNT"
+		if len(data.Params) > 0 && data.Params[0] != "*" {
+			data.Account = data.Params[0]
+		}
+	case "chghost":
+		// CHGHOST <new user> <new host>
+		data.Code = RPL_CHGHOST
+		data.CodeName = "RPL_CHGHOST"
+		if len(data.Params) > 1 {
+			data.Message = data.Params[0] + "@" + data.Params[1]
+		}
+	case "setname":
+		data.Code = RPL_SETNAME
+		data.CodeName = "RPL_SETNAME"
+		if len(data.Params) > 0 {
+			data.RealName = data.Params[0]
+			data.Message = data.Params[0]
+		}
+	case "invite":
+		// INVITE <nick> <channel>
+		data.Code = RPL_INVITE
+		data.CodeName = "RPL_INVITE"
+		if len(data.Params) > 1 {
+			data.Room = data.Params[1]
+		}
+	case "ack":
+		data.Code = RPL_ACK
+		data.CodeName = "RPL_ACK"
+		data.ServerName = strings.TrimPrefix(segments[0], ":")
+	case "chathistory":
+		data.Code = RPL_CHATHISTORY
+		data.CodeName = "RPL_CHATHISTORY"
+	case "batch":
+		data.Code = RPL_BATCH
+		data.CodeName = "RPL_BATCH"
+		data.ServerName = strings.TrimPrefix(segments[0], ":")
+	case "away":
+		data.Code = RPL_USERAWAY
+		data.CodeName = "RPL_USERAWAY"
+		if len(data.Params) > 0 {
+			data.Message = data.Params[0]
+		}
 	}
 
 	return data, true
 }
 
+//join failures are <me> <channel> :<reason>
+func joinFailure(params []string) (string, string) {
+	if len(params) < 3 {
+		return "", ""
+	}
+
+	return params[1], params[2]
+}
+
 func parseNumericReply(responseCode int, segments []string) (IncomingData, bool) {
 	data := IncomingData{}
 
+	// numerics we don't know by name still keep their code
+	data.Code = int32(responseCode)
 	data.ServerName = segments[0][1:]
 	data.Time = time.Now()
 	data.Nick = segments[2]
 	data.Message = strings.Join(segments[3:], " ")
+	data.Params = parseParams(segments[2:])
 
-	if data.Message[0] == ':' {
+	if len(data.Message) > 0 && data.Message[0] == ':' {
 		data.Message = data.Message[1:]
 	}
 
@@ -132,8 +389,15 @@ func parseNumericReply(responseCode int, segments []string) (IncomingData, bool)
 		data.Code = RPL_MYINFO
 		data.CodeName = "RPL_MYINFO"
 	case RPL_BOUNCE:
+		// most servers send 005 as RPL_ISUPPORT, only the old "Try server" form is a bounce
 		data.Code = RPL_BOUNCE
 		data.CodeName = "RPL_BOUNCE"
+		if !strings.HasPrefix(data.Message, "Try server") {
+			data.CodeName = "RPL_ISUPPORT"
+		}
+	case RPL_REDIR:
+		data.Code = RPL_REDIR
+		data.CodeName = "RPL_REDIR"
 	case RPL_LUSERCLIENT:
 		data.Code = RPL_LUSERCLIENT
 		data.CodeName = "RPL_LUSERCLIENT"
@@ -149,16 +413,45 @@ func parseNumericReply(responseCode int, segments []string) (IncomingData, bool)
 	case RPL_LUSERME:
 		data.Code = RPL_LUSERME
 		data.CodeName = "RPL_LUSERME"
+	case RPL_NOTOPIC:
+		data.Code = RPL_NOTOPIC
+		data.CodeName = "RPL_NOTOPIC"
+		data.Room = segments[3]
 	case RPL_TOPIC:
 		data.Code = RPL_TOPIC
 		data.CodeName = "RPL_TOPIC"
 		data.Room = segments[3]
-		data.Message = strings.Join(segments[4:], " ")
+		data.Message = data.Params[len(data.Params)-1]
+	case RPL_TOPICWHOTIME:
+		// <me> <channel> <setter> <unix time>
+		data.Code = RPL_TOPICWHOTIME
+		data.CodeName = "RPL_TOPICWHOTIME"
+		data.Room = segments[3]
+		if len(data.Params) > 3 {
+			data.Target = data.Params[2]
+			data.Message = data.Params[3]
+		}
+	case RPL_INVITING:
+		data.Code = RPL_INVITING
+		data.CodeName = "RPL_INVITING"
+		if len(data.Params) > 2 {
+			data.Room = data.Params[2]
+		}
+	case RPL_WHOREPLY:
+		data.Code = RPL_WHOREPLY
+		data.CodeName = "RPL_WHOREPLY"
+		data.Room = segments[3]
+	case RPL_WHOSPCRPL:
+		data.Code = RPL_WHOSPCRPL
+		data.CodeName = "RPL_WHOSPCRPL"
+	case RPL_ENDOFWHO:
+		data.Code = RPL_ENDOFWHO
+		data.CodeName = "RPL_ENDOFWHO"
 	case RPL_NAMREPLY:
 		data.Code = RPL_NAMREPLY
 		data.CodeName = "RPL_NAMREPLY"
 		data.Room = segments[4]
-		data.Message = strings.Join(segments[5:], ",")
+		data.Message = data.Params[len(data.Params)-1]
 	case RPL_ENDOFNAMES:
 		data.Code = RPL_ENDOFNAMES
 		data.CodeName = "RPL_ENDOFNAMES"
@@ -175,6 +468,69 @@ func parseNumericReply(responseCode int, segments []string) (IncomingData, bool)
 	case RPL_ENDOFMOTD:
 		data.Code = RPL_ENDOFMOTD
 		data.CodeName = "RPL_ENDOFMOTD"
+	case ERR_NOMOTD:
+		data.Code = ERR_NOMOTD
+		data.CodeName = "ERR_NOMOTD"
+	case RPL_AWAY:
+		data.Code = RPL_AWAY
+		data.CodeName = "RPL_AWAY"
+	case RPL_UNAWAY:
+		data.Code = RPL_UNAWAY
+		data.CodeName = "RPL_UNAWAY"
+	case RPL_NOWAWAY:
+		data.Code = RPL_NOWAWAY
+		data.CodeName = "RPL_NOWAWAY"
+	case RPL_ISON:
+		data.Code = RPL_ISON
+		data.CodeName = "RPL_ISON"
+	case RPL_MONONLINE:
+		data.Code = RPL_MONONLINE
+		data.CodeName = "RPL_MONONLINE"
+	case RPL_MONOFFLINE:
+		data.Code = RPL_MONOFFLINE
+		data.CodeName = "RPL_MONOFFLINE"
+	case RPL_MONLIST:
+		data.Code = RPL_MONLIST
+		data.CodeName = "RPL_MONLIST"
+	case RPL_ENDOFMONLIST:
+		data.Code = RPL_ENDOFMONLIST
+		data.CodeName = "RPL_ENDOFMONLIST"
+	case ERR_MONLISTFULL:
+		data.Code = ERR_MONLISTFULL
+		data.CodeName = "ERR_MONLISTFULL"
+	case RPL_YOUREOPER:
+		data.Code = RPL_YOUREOPER
+		data.CodeName = "RPL_YOUREOPER"
+	case RPL_LOGGEDIN:
+		data.Code = RPL_LOGGEDIN
+		data.CodeName = "RPL_LOGGEDIN"
+	case ERR_PASSWDMISMATCH:
+		data.Code = ERR_PASSWDMISMATCH
+		data.CodeName = "ERR_PASSWDMISMATCH"
+	case ERR_NOOPERHOST:
+		data.Code = ERR_NOOPERHOST
+		data.CodeName = "ERR_NOOPERHOST"
+	case ERR_NEEDMOREPARAMS:
+		data.Code = ERR_NEEDMOREPARAMS
+		data.CodeName = "ERR_NEEDMOREPARAMS"
+	case ERR_NOPRIVILEGES:
+		data.Code = ERR_NOPRIVILEGES
+		data.CodeName = "ERR_NOPRIVILEGES"
+	case RPL_ENDOFSTATS:
+		data.Code = RPL_ENDOFSTATS
+		data.CodeName = "RPL_ENDOFSTATS"
+	case ERR_INVITEONLYCHAN:
+		data.Code = ERR_INVITEONLYCHAN
+		data.CodeName = "ERR_INVITEONLYCHAN"
+		data.Room, data.Message = joinFailure(data.Params)
+	case ERR_BANNEDFROMCHAN:
+		data.Code = ERR_BANNEDFROMCHAN
+		data.CodeName = "ERR_BANNEDFROMCHAN"
+		data.Room, data.Message = joinFailure(data.Params)
+	case ERR_BADCHANNELKEY:
+		data.Code = ERR_BADCHANNELKEY
+		data.CodeName = "ERR_BADCHANNELKEY"
+		data.Room, data.Message = joinFailure(data.Params)
 	case RPL_FORWARDJOIN:
 		data.Code = RPL_FORWARDJOIN
 		data.CodeName = "RPL_FORWARDJOIN"
@@ -224,6 +580,9 @@ func parseNumericReply(responseCode int, segments []string) (IncomingData, bool)
 	case ERR_WASNOSUCHNICK:
 		data.Code = ERR_WASNOSUCHNICK
 		data.CodeName = "ERR_WASNOSUCHNICK"
+	case ERR_TOOMANYTARGETS:
+		data.Code = ERR_TOOMANYTARGETS
+		data.CodeName = "ERR_TOOMANYTARGETS"
 	case ERR_NORECIPIENT:
 		data.Code = ERR_NORECIPIENT
 		data.CodeName = "ERR_NORECIPIENT"
@@ -233,7 +592,37 @@ func parseNumericReply(responseCode int, segments []string) (IncomingData, bool)
 	case ERR_WILDTOPLEVEL:
 		data.Code = ERR_WILDTOPLEVEL
 		data.CodeName = "ERR_WILDTOPLEVEL"
+	case ERR_NONICKNAMEGIVEN:
+		data.Code = ERR_NONICKNAMEGIVEN
+		data.CodeName = "ERR_NONICKNAMEGIVEN"
+	case ERR_ERRONEUSNICKNAME:
+		data.Code = ERR_ERRONEUSNICKNAME
+		data.CodeName = "ERR_ERRONEUSNICKNAME"
+	case ERR_NICKNAMEINUSE:
+		data.Code = ERR_NICKNAMEINUSE
+		data.CodeName = "ERR_NICKNAMEINUSE"
+	case ERR_NICKCOLLISION:
+		data.Code = ERR_NICKCOLLISION
+		data.CodeName = "ERR_NICKCOLLISION"
+	case ERR_UNAVAILRESOURCE:
+		data.Code = ERR_UNAVAILRESOURCE
+		data.CodeName = "ERR_UNAVAILRESOURCE"
 	}
 
 	return data, true
 }
+
+//split the message parameters on spaces, everything after a parameter starting with ':' is the trailing parameter
+func parseParams(segments []string) []string {
+	params := make([]string, 0, len(segments))
+
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			params = append(params, strings.Join(segments[index:], " ")[1:])
+			break
+		}
+		params = append(params, segment)
+	}
+
+	return params
+}
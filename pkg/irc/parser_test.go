@@ -0,0 +1,70 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTags(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want map[string]string
+	}{
+		{"time=2019-01-01T00:00:00.000Z;msgid=abc", map[string]string{"time": "2019-01-01T00:00:00.000Z", "msgid": "abc"}},
+		// a tag without a value and an empty tag
+		{"draft/bot;;+example.com/flag=", map[string]string{"draft/bot": "", "+example.com/flag": ""}},
+		{`note=a\sb\:c\\d\re\nf`, map[string]string{"note": "a b;c\\d\re\nf"}},
+		// unknown escapes drop the backslash, a trailing one is dropped too
+		{`a=\x\`, map[string]string{"a": "x"}},
+		// the last value of a repeated key wins
+		{"a=1;a=2", map[string]string{"a": "2"}},
+	}
+
+	for _, test := range tests {
+		if got := parseTags(test.raw); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseTags(%q) = %v, want %v", test.raw, got, test.want)
+		}
+	}
+}
+
+func TestParseRawInputTags(t *testing.T) {
+	line, ok := parseRawInput("@account=joeacct;msgid=x1 :joe!u@h PRIVMSG #go :hi")
+	if !ok {
+		t.Fatal("the tagged line didn't parse")
+	}
+	if line.Account != "joeacct" || line.Tags["msgid"] != "x1" || line.Nick != "joe" || line.Params[1] != "hi" {
+		t.Errorf("got %+v", line)
+	}
+
+	if _, ok := parseRawInput("@time=2019-01-01T00:00:00Z"); ok {
+		t.Error("tags without a message parsed")
+	}
+}
+
+func TestApplyServerTime(t *testing.T) {
+	stamp := time.Date(2019, 1, 1, 12, 30, 0, 500000000, time.UTC)
+
+	tests := []struct {
+		name string
+		cap  bool
+		tag  string
+		want bool
+	}{
+		{"enabled", true, "2019-01-01T12:30:00.500Z", true},
+		{"not negotiated", false, "2019-01-01T12:30:00.500Z", false},
+		{"invalid", true, "yesterday", false},
+	}
+
+	for _, test := range tests {
+		c := NewClient("me", "", "irc.example")
+		c.caps = map[string]bool{"server-time": test.cap}
+
+		line, _ := parseRawInput("@time=" + test.tag + " :joe!u@h PRIVMSG #go :hi")
+		c.applyServerTime(&line)
+
+		if line.ServerTime != test.want || (test.want && !line.Time.Equal(stamp)) {
+			t.Errorf("%s: ServerTime %v, Time %v", test.name, line.ServerTime, line.Time)
+		}
+	}
+}
@@ -0,0 +1,294 @@
+package irc
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//keep MONITOR and ISON lines well below the 512 byte limit
+const presenceLineLen = 400
+
+type watchedNick struct {
+	nick      string
+	mask      string
+	online    bool
+	known     bool
+	monitored bool
+}
+
+//presence keeps track of the nicks we were asked to watch, using MONITOR when the server
+//supports it and falling back to polling with ISON
+type presence struct {
+	mu        sync.Mutex
+	watched   map[string]*watchedNick
+	isonQueue [][]string
+}
+
+func newPresence() *presence {
+	return &presence{
+		watched: make(map[string]*watchedNick),
+	}
+}
+
+//Watch adds the nicks to the list of nicks we want EventOnline and EventOffline events for
+func (c *Client) Watch(nicks ...string) {
+	var added []string
+
+	c.presence.mu.Lock()
+	for _, nick := range nicks {
+		nick = strings.TrimSpace(nick)
+		key := strings.ToLower(nick)
+
+		if _, ok := c.presence.watched[key]; len(nick) > 0 && !ok {
+			c.presence.watched[key] = &watchedNick{nick: nick}
+			added = append(added, nick)
+		}
+	}
+	c.presence.mu.Unlock()
+
+	if c.isReady() {
+		c.monitorNicks(added)
+	}
+}
+
+//Unwatch removes the nicks from the watch list
+func (c *Client) Unwatch(nicks ...string) {
+	var monitored []string
+
+	c.presence.mu.Lock()
+	for _, nick := range nicks {
+		key := strings.ToLower(strings.TrimSpace(nick))
+
+		if watched, ok := c.presence.watched[key]; ok {
+			if watched.monitored {
+				monitored = append(monitored, watched.nick)
+			}
+			delete(c.presence.watched, key)
+		}
+	}
+	c.presence.mu.Unlock()
+
+	if c.isReady() {
+		for _, chunk := range chunkNicks(monitored, presenceLineLen) {
+			c.server.monitor("-", chunk...)
+		}
+	}
+}
+
+//IsOnline returns whether a watched nick is online, known is false until the server told us
+func (c *Client) IsOnline(nick string) (online bool, known bool) {
+	c.presence.mu.Lock()
+	defer c.presence.mu.Unlock()
+
+	if watched, ok := c.presence.watched[strings.ToLower(nick)]; ok {
+		return watched.online, watched.known
+	}
+
+	return false, false
+}
+
+//add as many nicks to MONITOR as the server allows, anything left over is polled with ISON
+func (c *Client) monitorNicks(nicks []string) {
+	limit, ok := c.isupport.Get("MONITOR")
+	if !ok || len(nicks) == 0 {
+		return
+	}
+
+	c.presence.mu.Lock()
+	available := len(nicks)
+	if max, err := strconv.Atoi(limit); err == nil && max > 0 {
+		used := 0
+		for _, watched := range c.presence.watched {
+			if watched.monitored {
+				used++
+			}
+		}
+		available = max - used
+	}
+
+	var add []string
+	for _, nick := range nicks {
+		if len(add) >= available {
+			break
+		}
+		if watched, ok := c.presence.watched[strings.ToLower(nick)]; ok && !watched.monitored {
+			watched.monitored = true
+			add = append(add, watched.nick)
+		}
+	}
+	c.presence.mu.Unlock()
+
+	for _, chunk := range chunkNicks(add, presenceLineLen) {
+		c.server.monitor("+", chunk...)
+	}
+}
+
+//called once registration is complete
+func (c *Client) startPresence() {
+	c.presence.mu.Lock()
+	nicks := make([]string, 0, len(c.presence.watched))
+	for _, watched := range c.presence.watched {
+		nicks = append(nicks, watched.nick)
+	}
+	c.presence.mu.Unlock()
+
+	c.monitorNicks(nicks)
+	c.pollPresence()
+}
+
+//send ISON for every watched nick that isn't on our MONITOR list
+func (c *Client) pollPresence() {
+	var nicks []string
+
+	c.presence.mu.Lock()
+	for _, watched := range c.presence.watched {
+		if !watched.monitored {
+			nicks = append(nicks, watched.nick)
+		}
+	}
+
+	chunks := chunkNicks(nicks, presenceLineLen)
+	c.presence.isonQueue = append(c.presence.isonQueue, chunks...)
+	c.presence.mu.Unlock()
+
+	for _, chunk := range chunks {
+		c.server.ison(chunk...)
+	}
+}
+
+//poll the watched nicks with ISON every PresenceFreq
+func (c *Client) presenceTicker(ctx context.Context) {
+	ticker := time.NewTicker(c.PresenceFreq)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if c.isReady() {
+				c.pollPresence()
+			}
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+//update the presence state from RPL_ISON and the MONITOR numerics
+func (c *Client) handlePresence(line IncomingData) {
+	if len(line.Params) < 2 {
+		return
+	}
+
+	switch line.Code {
+	case RPL_ISON:
+		c.presence.mu.Lock()
+		if len(c.presence.isonQueue) == 0 {
+			c.presence.mu.Unlock()
+			return
+		}
+		asked := c.presence.isonQueue[0]
+		c.presence.isonQueue = c.presence.isonQueue[1:]
+		c.presence.mu.Unlock()
+
+		online := make(map[string]bool)
+		for _, nick := range strings.Fields(line.Params[1]) {
+			online[strings.ToLower(nick)] = true
+		}
+
+		for _, nick := range asked {
+			c.setPresence(nick, "", online[strings.ToLower(nick)])
+		}
+
+	case RPL_MONONLINE, RPL_MONOFFLINE:
+		for _, target := range strings.Split(line.Params[1], ",") {
+			nick := target
+			if index := strings.Index(target, "!"); index != -1 {
+				nick = target[:index]
+			}
+			c.setPresence(nick, target, line.Code == RPL_MONONLINE)
+		}
+
+	case ERR_MONLISTFULL:
+		// the nicks that didn't fit are polled with ISON instead
+		if len(line.Params) < 3 {
+			return
+		}
+
+		c.presence.mu.Lock()
+		for _, nick := range strings.Split(line.Params[2], ",") {
+			if watched, ok := c.presence.watched[strings.ToLower(nick)]; ok {
+				watched.monitored = false
+			}
+		}
+		c.presence.mu.Unlock()
+	}
+}
+
+//record the state of a watched nick and send EventOnline or EventOffline if it changed
+func (c *Client) setPresence(nick, mask string, online bool) {
+	c.presence.mu.Lock()
+	watched, ok := c.presence.watched[strings.ToLower(nick)]
+	if !ok || (watched.known && watched.online == online) {
+		c.presence.mu.Unlock()
+		return
+	}
+
+	watched.known = true
+	watched.online = online
+	if len(mask) > 0 {
+		watched.mask = mask
+	}
+	mask = watched.mask
+	c.presence.mu.Unlock()
+
+	event := EventOffline
+	if online {
+		event = EventOnline
+	}
+
+	if callback, ok := c.callbackHandlers[event]; ok {
+		callback(EventType{
+			Nick:    nick,
+			Message: mask,
+			Time:    time.Now(),
+		})
+	}
+}
+
+//forget what the server told us, MONITOR lists don't survive a reconnect
+func (c *Client) resetPresence() {
+	c.presence.mu.Lock()
+	for _, watched := range c.presence.watched {
+		watched.known = false
+		watched.monitored = false
+	}
+	c.presence.isonQueue = nil
+	c.presence.mu.Unlock()
+}
+
+//split the nicks into groups that fit in a single line
+func chunkNicks(nicks []string, maxLen int) [][]string {
+	var chunks [][]string
+	var chunk []string
+	length := 0
+
+	for _, nick := range nicks {
+		if len(chunk) > 0 && length+len(nick)+1 > maxLen {
+			chunks = append(chunks, chunk)
+			chunk = nil
+			length = 0
+		}
+		chunk = append(chunk, nick)
+		length += len(nick) + 1
+	}
+
+	if len(chunk) > 0 {
+		chunks = append(chunks, chunk)
+	}
+
+	return chunks
+}
@@ -0,0 +1,90 @@
+package irc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPresence(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+	c.Watch("joe", "ann")
+
+	events := make(chan EventType, 5)
+	c.HandleEventFunc(EventOnline, func(event EventType) {
+		events <- event
+	})
+	c.HandleEventFunc(EventOffline, func(event EventType) {
+		events <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.expect("USER")
+
+	// the server only has room for one nick, the other one is polled with ISON
+	server.send(":srv 001 me :Welcome", ":srv 005 me MONITOR=1 :are supported", ":srv 376 me :End of MOTD")
+	monitored := strings.TrimPrefix(server.expect("MONITOR + "), "MONITOR + ")
+	polled := strings.TrimPrefix(server.expect("ISON "), "ISON ")
+	if monitored == polled || (monitored != "joe" && monitored != "ann") || (polled != "joe" && polled != "ann") {
+		t.Fatalf("monitored %q and polled %q, want one each of joe and ann", monitored, polled)
+	}
+
+	if _, known := c.IsOnline(monitored); known {
+		t.Errorf("%s is known before the server told us", monitored)
+	}
+
+	tests := []struct {
+		line   string
+		event  string
+		nick   string
+		mask   string
+		online bool
+	}{
+		{":srv 730 me :" + monitored + "!u@h", EventOnline, monitored, monitored + "!u@h", true},
+		{":srv 303 me :" + polled, EventOnline, polled, "", true},
+		// RPL_MONOFFLINE only has the nick
+		{":srv 731 me :" + monitored, EventOffline, monitored, monitored, false},
+	}
+
+	for _, test := range tests {
+		server.send(test.line)
+
+		event := <-events
+		if event.Nick != test.nick || event.Message != test.mask {
+			t.Errorf("%s: got the event for %q %q, want %q %q", test.line, event.Nick, event.Message, test.nick, test.mask)
+		}
+		if online, known := c.IsOnline(test.nick); online != test.online || !known {
+			t.Errorf("%s: IsOnline(%q) = %v, %v, want %v", test.line, test.nick, online, known, test.online)
+		}
+	}
+
+	select {
+	case event := <-events:
+		t.Errorf("unexpected event for %q", event.Nick)
+	default:
+	}
+}
+
+func TestChunkNicks(t *testing.T) {
+	tests := []struct {
+		nicks  []string
+		maxLen int
+		want   [][]string
+	}{
+		{nil, 10, nil},
+		{[]string{"joe", "ann"}, 10, [][]string{{"joe", "ann"}}},
+		{[]string{"joe", "ann", "bob"}, 8, [][]string{{"joe", "ann"}, {"bob"}}},
+		// a nick longer than the limit gets a line of its own
+		{[]string{"joe", "verylongnick", "ann"}, 8, [][]string{{"joe"}, {"verylongnick"}, {"ann"}}},
+	}
+
+	for _, test := range tests {
+		if got := chunkNicks(test.nicks, test.maxLen); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("chunkNicks(%v, %d) = %v, want %v", test.nicks, test.maxLen, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+//the kinds of proxy a connection can go through
+const (
+	//ProxySOCKS5 a SOCKS5 proxy, with a username and password when they are set
+	ProxySOCKS5 = "socks5"
+	//ProxyHTTP an HTTP proxy that supports CONNECT, with basic auth when a username is set
+	ProxyHTTP = "http"
+)
+
+//Proxy the proxy the connection to the server goes through. The proxy resolves the server's host, and TLS to
+//the server is done inside the tunnel
+type Proxy struct {
+	Kind string
+	//Address the host:port of the proxy
+	Address  string
+	Username string
+	Password string
+}
+
+//SOCKS5 reply codes, RFC 1928 section 6
+var socksReplies = map[byte]string{
+	1: "general SOCKS server failure",
+	2: "connection not allowed by ruleset",
+	3: "network unreachable",
+	4: "host unreachable",
+	5: "connection refused",
+	6: "TTL expired",
+	7: "command not supported",
+	8: "address type not supported",
+}
+
+//dial connects to the proxy and asks it for a tunnel to host:port
+func (p *Proxy) dial(ctx context.Context, dialer net.Dialer, network, host, port string) (net.Conn, error) {
+	if p.Kind != ProxySOCKS5 && p.Kind != ProxyHTTP {
+		return nil, fmt.Errorf("Unknown proxy kind %q", p.Kind)
+	}
+
+	conn, err := dialer.DialContext(ctx, network, p.Address)
+	if err != nil {
+		return nil, fmt.Errorf("Proxy %s: %v", p.Address, err)
+	}
+
+	//the handshake is bound by the same timeout as the dial
+	deadline, ok := ctx.Deadline()
+	if dialer.Timeout > 0 && (!ok || time.Now().Add(dialer.Timeout).Before(deadline)) {
+		deadline, ok = time.Now().Add(dialer.Timeout), true
+	}
+	if ok {
+		conn.SetDeadline(deadline)
+	}
+
+	//closing the connection ends a handshake that is still waiting on the proxy when ctx is done
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+
+	if p.Kind == ProxySOCKS5 {
+		err = p.socksConnect(conn, host, port)
+	} else {
+		conn, err = p.httpConnect(conn, host, port)
+	}
+	if !stop() && err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("Proxy %s: %v", p.Address, err)
+	}
+
+	conn.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+//socksConnect the SOCKS5 handshake of RFC 1928, with the username and password auth of RFC 1929
+func (p *Proxy) socksConnect(conn net.Conn, host, port string) error {
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		return fmt.Errorf("Invalid port %q", port)
+	}
+
+	method := byte(0x00)
+	if len(p.Username) > 0 {
+		method = 0x02
+	}
+
+	if _, err := conn.Write([]byte{0x05, 0x01, method}); err != nil {
+		return err
+	}
+
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil {
+		return err
+	}
+	if reply[0] != 0x05 {
+		return errors.New("Not a SOCKS5 proxy")
+	}
+	if reply[1] != method {
+		return errors.New("The SOCKS5 proxy refused our auth method")
+	}
+
+	if method == 0x02 {
+		if len(p.Username) > 255 || len(p.Password) > 255 {
+			return errors.New("The SOCKS5 username and password can be at most 255 bytes")
+		}
+
+		auth := []byte{0x01, byte(len(p.Username))}
+		auth = append(auth, p.Username...)
+		auth = append(auth, byte(len(p.Password)))
+		auth = append(auth, p.Password...)
+		if _, err := conn.Write(auth); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			return err
+		}
+		if reply[0] != 0x01 {
+			return errors.New("The SOCKS5 proxy answered the auth with an unknown version")
+		}
+		if reply[1] != 0x00 {
+			return errors.New("The SOCKS5 proxy rejected the username or password")
+		}
+	}
+
+	request := []byte{0x05, 0x01, 0x00}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() != nil {
+		request = append(request, 0x01)
+		request = append(request, ip.To4()...)
+	} else if ip != nil {
+		request = append(request, 0x04)
+		request = append(request, ip.To16()...)
+	} else {
+		if len(host) > 255 {
+			return errors.New("The host name is too long for SOCKS5")
+		}
+		request = append(request, 0x03, byte(len(host)))
+		request = append(request, host...)
+	}
+	request = append(request, byte(number>>8), byte(number))
+
+	if _, err := conn.Write(request); err != nil {
+		return err
+	}
+
+	//version, reply, reserved and the type of the bound address that follows
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	if header[0] != 0x05 {
+		return errors.New("Not a SOCKS5 reply")
+	}
+	if header[1] != 0x00 {
+		if reason, ok := socksReplies[header[1]]; ok {
+			return errors.New(reason)
+		}
+		return fmt.Errorf("SOCKS5 reply %d", header[1])
+	}
+
+	var skip int
+	switch header[3] {
+	case 0x01:
+		skip = net.IPv4len
+	case 0x04:
+		skip = net.IPv6len
+	case 0x03:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return err
+		}
+		skip = int(length[0])
+	default:
+		return fmt.Errorf("Unknown SOCKS5 address type %d", header[3])
+	}
+
+	//the bound address and port aren't needed
+	_, err = io.ReadFull(conn, make([]byte, skip+2))
+	return err
+}
+
+//httpConnect asks an HTTP proxy to CONNECT to the server. The connection returned keeps anything the
+//server sent that was read along with the proxy's response
+func (p *Proxy) httpConnect(conn net.Conn, host, port string) (net.Conn, error) {
+	target := net.JoinHostPort(host, port)
+
+	request := "CONNECT " + target + " HTTP/1.1\r\nHost: " + target + "\r\n"
+	if len(p.Username) > 0 {
+		credentials := base64.StdEncoding.EncodeToString([]byte(p.Username + ":" + p.Password))
+		request += "Proxy-Authorization: Basic " + credentials + "\r\n"
+	}
+	request += "\r\n"
+
+	if _, err := io.WriteString(conn, request); err != nil {
+		return conn, err
+	}
+
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		return conn, err
+	}
+	response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return conn, errors.New("CONNECT refused: " + response.Status)
+	}
+
+	return &bufferedConn{Conn: conn, reader: reader}, nil
+}
+
+//bufferedConn reads what is left in the reader before reading from the connection
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (b *bufferedConn) Read(p []byte) (int, error) {
+	if b.reader.Buffered() > 0 {
+		return b.reader.Read(p)
+	}
+
+	return b.Conn.Read(p)
+}
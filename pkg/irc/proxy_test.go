@@ -0,0 +1,289 @@
+package irc
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+//standIn a proxy that runs handle for each connection, it returns the proxy's address
+func standIn(t *testing.T, handle func(conn net.Conn)) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+//socksStandIn a SOCKS5 proxy that wants the username and password when user is set. It answers the auth
+//with authVersion and sends the CONNECT target to targets. With tunnel it connects to the target, otherwise
+//it sends a greeting in place of the server
+func socksStandIn(t *testing.T, user, pass string, authVersion byte, tunnel bool, targets chan string) string {
+	return standIn(t, func(conn net.Conn) {
+		header := make([]byte, 2)
+		if _, err := io.ReadFull(conn, header); err != nil {
+			return
+		}
+		io.ReadFull(conn, make([]byte, header[1]))
+
+		method := byte(0x00)
+		if len(user) > 0 {
+			method = 0x02
+		}
+		conn.Write([]byte{0x05, method})
+
+		if method == 0x02 {
+			io.ReadFull(conn, header)
+			username := make([]byte, header[1])
+			io.ReadFull(conn, username)
+			io.ReadFull(conn, header[:1])
+			password := make([]byte, header[0])
+			io.ReadFull(conn, password)
+
+			if string(username) != user || string(password) != pass {
+				conn.Write([]byte{authVersion, 0x01})
+				return
+			}
+			conn.Write([]byte{authVersion, 0x00})
+		}
+
+		request := make([]byte, 4)
+		if _, err := io.ReadFull(conn, request); err != nil {
+			return
+		}
+
+		var host string
+		switch request[3] {
+		case 0x01:
+			ip := make([]byte, net.IPv4len)
+			io.ReadFull(conn, ip)
+			host = net.IP(ip).String()
+		case 0x03:
+			io.ReadFull(conn, header[:1])
+			name := make([]byte, header[0])
+			io.ReadFull(conn, name)
+			host = string(name)
+		}
+		port := make([]byte, 2)
+		io.ReadFull(conn, port)
+
+		target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+		targets <- target
+
+		if !tunnel {
+			conn.Write([]byte{0x05, 0x00, 0x00, 0x01, 127, 0, 0, 1, 0x1a, 0x0b})
+			conn.Write([]byte("hello\r\n"))
+			io.Copy(io.Discard, conn)
+			return
+		}
+
+		server, err := net.Dial("tcp", target)
+		if err != nil {
+			conn.Write([]byte{0x05, 0x05, 0x00, 0x01, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer server.Close()
+
+		// a domain name as the bound address
+		conn.Write([]byte{0x05, 0x00, 0x00, 0x03, 4, 'p', 'r', 'o', 'x', 0x1a, 0x0b})
+		go io.Copy(server, conn)
+		io.Copy(conn, server)
+	})
+}
+
+func TestSocksProxy(t *testing.T) {
+	tests := []struct {
+		name        string
+		user, pass  string
+		authVersion byte
+		proxy       Proxy
+		port        string
+		wantErr     string
+	}{
+		{name: "no auth", port: "6697"},
+		{name: "auth", user: "bob", pass: "pw", authVersion: 0x01, proxy: Proxy{Username: "bob", Password: "pw"}, port: "6697"},
+		{name: "wrong password", user: "bob", pass: "pw", authVersion: 0x01, proxy: Proxy{Username: "bob", Password: "nope"}, port: "6697", wantErr: "rejected"},
+		{name: "auth version", user: "bob", pass: "pw", authVersion: 0x05, proxy: Proxy{Username: "bob", Password: "pw"}, port: "6697", wantErr: "unknown version"},
+		{name: "port out of range", port: "70000", wantErr: "Invalid port"},
+	}
+
+	for _, test := range tests {
+		targets := make(chan string, 1)
+
+		proxy := test.proxy
+		proxy.Kind = ProxySOCKS5
+		proxy.Address = socksStandIn(t, test.user, test.pass, test.authVersion, false, targets)
+
+		conn, err := proxy.dial(context.Background(), net.Dialer{Timeout: 3 * time.Second}, "tcp", "irc.example", test.port)
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got the error %v, want one containing %q", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if target := <-targets; target != "irc.example:6697" {
+			t.Errorf("%s: the proxy was asked for %s", test.name, target)
+		}
+		if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "hello\r\n" {
+			t.Errorf("%s: read %q, %v through the proxy", test.name, line, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestSocksProxyReplyVersion(t *testing.T) {
+	// accepts the greeting, then answers the CONNECT like an HTTP server would
+	address := standIn(t, func(conn net.Conn) {
+		io.ReadFull(conn, make([]byte, 3))
+		conn.Write([]byte{0x05, 0x00})
+		io.ReadFull(conn, make([]byte, 4+1+len("irc.example")+2))
+		conn.Write([]byte("HTTP/1.1 400 Bad Request\r\n\r\n"))
+	})
+
+	proxy := Proxy{Kind: ProxySOCKS5, Address: address}
+	_, err := proxy.dial(context.Background(), net.Dialer{Timeout: 3 * time.Second}, "tcp", "irc.example", "6697")
+	if err == nil || !strings.Contains(err.Error(), "Not a SOCKS5 reply") {
+		t.Errorf("got the error %v, want the reply to be rejected", err)
+	}
+}
+
+func TestHTTPProxy(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		username string
+		wantAuth string
+		wantErr  string
+	}{
+		// the server's first line arrives in the same read as the proxy's response
+		{name: "connect", response: "HTTP/1.1 200 Connection established\r\n\r\nhello\r\n"},
+		{name: "auth", response: "HTTP/1.1 200 OK\r\n\r\nhello\r\n", username: "bob", wantAuth: "Basic Ym9iOnB3"},
+		{name: "auth required", response: "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n", wantErr: "407"},
+	}
+
+	for _, test := range tests {
+		requests := make(chan *http.Request, 1)
+		address := standIn(t, func(conn net.Conn) {
+			request, err := http.ReadRequest(bufio.NewReader(conn))
+			if err != nil {
+				return
+			}
+			requests <- request
+
+			conn.Write([]byte(test.response))
+			io.Copy(io.Discard, conn)
+		})
+
+		proxy := Proxy{Kind: ProxyHTTP, Address: address, Username: test.username, Password: "pw"}
+		conn, err := proxy.dial(context.Background(), net.Dialer{Timeout: 3 * time.Second}, "tcp", "irc.example", "6697")
+
+		request := <-requests
+		if request.Method != http.MethodConnect || request.Host != "irc.example:6697" {
+			t.Errorf("%s: the proxy got %s %s", test.name, request.Method, request.Host)
+		}
+		if auth := request.Header.Get("Proxy-Authorization"); auth != test.wantAuth {
+			t.Errorf("%s: Proxy-Authorization %q, want %q", test.name, auth, test.wantAuth)
+		}
+
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got the error %v, want one containing %q", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+
+		if line, err := bufio.NewReader(conn).ReadString('\n'); err != nil || line != "hello\r\n" {
+			t.Errorf("%s: read %q, %v through the proxy", test.name, line, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestProxyTLS(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	server := &testServer{t: t, listener: listener}
+
+	targets := make(chan string, 1)
+	roots := x509.NewCertPool()
+	roots.AddCert(certificate)
+
+	// the proxy resolves localhost, the certificate is checked against it inside the tunnel
+	c := testClient(ServerAddress{Host: "localhost", Port: server.address().Port, UseTLS: true})
+	c.TLSConfig = &tls.Config{RootCAs: roots}
+	c.Proxy = &Proxy{Kind: ProxySOCKS5, Address: socksStandIn(t, "", "", 0, true, targets)}
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.expect("CAP LS")
+
+	if target := <-targets; target != net.JoinHostPort("localhost", strconv.Itoa(int(server.address().Port))) {
+		t.Errorf("the proxy was asked for %s", target)
+	}
+}
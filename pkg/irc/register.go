@@ -0,0 +1,176 @@
+package irc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+//ChannelKey a channel to join along with its key, Key is empty for channels without one
+type ChannelKey struct {
+	Name string
+	Key  string
+}
+
+//RegisterAction an action run once registration is complete, that is once the server sent the end of the
+//MOTD. SASL isn't supported, so there is no SASL step for actions to wait for, use IdentifyAction and
+//WaitForServicesAction instead. Actions run in order and may block, e.g. to wait for a reply, until the ctx
+//is done, which happens after the client's RegisterTimeout
+type RegisterAction func(ctx context.Context, c *Client) error
+
+//pendingReply a reply a command registered for before it was sent, see expect
+type pendingReply struct {
+	wait   func(ctx context.Context) (IncomingData, error)
+	remove func()
+}
+
+//IdentifyAction identifies with NickServ using the password. A WaitForServicesAction after it waits for the
+//answer to this IDENTIFY, even if services answered before it started waiting
+func IdentifyAction(password string) RegisterAction {
+	return func(ctx context.Context, c *Client) error {
+		wait, remove := c.expect(servicesAnswer)
+		if err := c.server.privMessage("NickServ", "IDENTIFY "+password); err != nil {
+			remove()
+			return err
+		}
+
+		c.mu.Lock()
+		if c.identifyReply != nil {
+			c.identifyReply.remove()
+		}
+		c.identifyReply = &pendingReply{wait: wait, remove: remove}
+		c.mu.Unlock()
+
+		return nil
+	}
+}
+
+//ModeAction sets user modes on ourselves, e.g. +iw
+func ModeAction(modes string) RegisterAction {
+	return func(ctx context.Context, c *Client) error {
+		return c.server.mode(c.Nick(), modes)
+	}
+}
+
+//OperAction becomes an irc operator and waits for the server to accept it
+func OperAction(name, password string) RegisterAction {
+	return func(ctx context.Context, c *Client) error {
+		return c.Oper(ctx, name, password)
+	}
+}
+
+//WaitForServicesAction waits until services logged us in (RPL_LOGGEDIN) or NickServ sent us a notice.
+//Running out of time isn't an error, the next action runs either way
+func WaitForServicesAction(timeout time.Duration) RegisterAction {
+	return func(ctx context.Context, c *Client) error {
+		c.mu.Lock()
+		reply := c.identifyReply
+		c.identifyReply = nil
+		c.mu.Unlock()
+
+		// without an IDENTIFY before it, wait for whatever services send next
+		if reply == nil {
+			wait, remove := c.expect(servicesAnswer)
+			reply = &pendingReply{wait: wait, remove: remove}
+		}
+
+		waitCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		if _, err := reply.wait(waitCtx); err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		return nil
+	}
+}
+
+//services logged us in or NickServ answered
+func servicesAnswer(line IncomingData) bool {
+	return line.Code == RPL_LOGGEDIN || (line.Code == RPL_NOTICE && strings.EqualFold(line.Nick, "NickServ"))
+}
+
+//DelayAction waits before running the next action
+func DelayAction(delay time.Duration) RegisterAction {
+	return func(ctx context.Context, c *Client) error {
+		select {
+		case <-time.After(delay):
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+//expect registers for the first line match accepts before a command is sent, the returned wait func waits
+//for it. remove drops the hook without waiting, e.g. when the command couldn't be sent
+func (c *Client) expect(match func(IncomingData) bool) (wait func(ctx context.Context) (IncomingData, error), remove func()) {
+	found := make(chan IncomingData, 1)
+
+	remove = c.addHook(func(line IncomingData) bool {
+		if match(line) {
+			found <- line
+			return true
+		}
+
+		return false
+	})
+
+	wait = func(ctx context.Context) (IncomingData, error) {
+		select {
+		case line := <-found:
+			return line, nil
+		case <-ctx.Done():
+			remove()
+			return IncomingData{}, ctx.Err()
+		}
+	}
+
+	return wait, remove
+}
+
+//run the OnRegister actions and then join the AutoJoin channels. This runs on its own go routine, errors
+//go through the listen loop so EventError isn't called at the same time as the other callbacks
+func (c *Client) afterRegister(ctx context.Context) {
+	for index, action := range c.OnRegister {
+		if err := c.runAction(ctx, action); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+
+			c.reportError(ctx, fmt.Errorf("Register action %d failed: %w", index+1, err))
+		}
+	}
+
+	// an IDENTIFY nobody waited for
+	c.mu.Lock()
+	if c.identifyReply != nil {
+		c.identifyReply.remove()
+		c.identifyReply = nil
+	}
+	c.mu.Unlock()
+
+	if err := c.server.joinChannels(c.AutoJoin); err != nil {
+		c.reportError(ctx, fmt.Errorf("AutoJoin failed: %w", err))
+	}
+}
+
+//run the action with the RegisterTimeout, so one that never gets an answer doesn't hold up the rest
+func (c *Client) runAction(ctx context.Context, action RegisterAction) error {
+	if c.RegisterTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RegisterTimeout)
+		defer cancel()
+	}
+
+	return action(ctx, c)
+}
+
+//hand the error to the listen loop, which calls EventError the way it does for connection errors
+func (c *Client) reportError(ctx context.Context, err error) {
+	select {
+	case c.server.errChan <- err:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,55 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRegisterActions(t *testing.T) {
+	server := newTestServer(t)
+
+	c := testClient(server.address())
+	c.RegisterTimeout = 500 * time.Millisecond
+	c.AutoJoin = []ChannelKey{{Name: "#go"}}
+	c.OnRegister = []RegisterAction{
+		IdentifyAction("pw"),
+		DelayAction(50 * time.Millisecond),
+		// services answered during the delay, the wait still sees it
+		WaitForServicesAction(5 * time.Second),
+		// the server never answers OPER
+		OperAction("me", "secret"),
+	}
+
+	errs := make(chan EventType, 5)
+	c.HandleEventFunc(EventError, func(event EventType) {
+		errs <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.register("me")
+	server.expect("PRIVMSG NickServ :IDENTIFY pw")
+	server.send(":NickServ!s@services NOTICE me :Password accepted")
+	server.expect("OPER me secret")
+	server.expect("JOIN #go")
+
+	select {
+	case event := <-errs:
+		if !strings.HasPrefix(event.Message, "Register action 4 failed") || !errors.Is(event.Err, context.DeadlineExceeded) {
+			t.Errorf("got the error %q, want action 4 to time out", event.Message)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for the error of the oper action")
+	}
+
+	select {
+	case event := <-errs:
+		t.Errorf("unexpected error %q", event.Message)
+	default:
+	}
+}
@@ -0,0 +1,214 @@
+package irc
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//CommandSpec describes a command that can be sent with Client.Command. The args are checked against
+//MinArgs and MaxArgs before Encode turns them into the messages sent to the server. A MaxArgs of -1
+//allows any number of args
+type CommandSpec struct {
+	Name    string
+	MinArgs int
+	MaxArgs int
+	Encode  func(args []string) ([]*Message, error)
+}
+
+type commandRegistry struct {
+	mu    sync.RWMutex
+	specs map[string]CommandSpec
+}
+
+func newCommandRegistry() *commandRegistry {
+	registry := &commandRegistry{
+		specs: make(map[string]CommandSpec),
+	}
+
+	for _, spec := range builtinCommands() {
+		registry.specs[spec.Name] = spec
+	}
+
+	return registry
+}
+
+func (r *commandRegistry) get(name string) (CommandSpec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	spec, ok := r.specs[strings.ToLower(name)]
+	return spec, ok
+}
+
+func (r *commandRegistry) set(spec CommandSpec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.specs[strings.ToLower(spec.Name)] = spec
+}
+
+func (r *commandRegistry) remove(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.specs, strings.ToLower(name))
+}
+
+//check the arity of the args and encode them into lines, nothing is sent if any of them is invalid
+func (spec CommandSpec) lines(args []string) ([]string, error) {
+	if len(args) < spec.MinArgs {
+		return nil, fmt.Errorf("%s needs at least %d args, got %d", strings.ToUpper(spec.Name), spec.MinArgs, len(args))
+	}
+	if spec.MaxArgs >= 0 && len(args) > spec.MaxArgs {
+		return nil, fmt.Errorf("%s takes at most %d args, got %d", strings.ToUpper(spec.Name), spec.MaxArgs, len(args))
+	}
+
+	messages, err := spec.Encode(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var lines []string
+	for _, message := range messages {
+		line, err := message.Encode()
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, line)
+	}
+
+	return lines, nil
+}
+
+//RegisterCommand adds a command that can be sent with Command, a command with the same name is replaced.
+//Names are case insensitive
+func (c *Client) RegisterCommand(spec CommandSpec) error {
+	if len(spec.Name) == 0 || strings.ContainsAny(spec.Name, " \r\n") {
+		return fmt.Errorf("Invalid command name %q", spec.Name)
+	}
+	if spec.Encode == nil {
+		return fmt.Errorf("Command %s has no Encode func", spec.Name)
+	}
+	if spec.MaxArgs >= 0 && spec.MaxArgs < spec.MinArgs {
+		return fmt.Errorf("Command %s has a MaxArgs lower than its MinArgs", spec.Name)
+	}
+
+	c.commands.set(spec)
+	return nil
+}
+
+//UnregisterCommand removes a command, including the built in ones
+func (c *Client) UnregisterCommand(name string) {
+	c.commands.remove(name)
+}
+
+//Raw sends the line to the server as is. The line must not contain a line break
+func (c *Client) Raw(line string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+	if len(line) == 0 || strings.ContainsAny(line, "\r\n\x00") {
+		return errors.New("A raw line can't be empty or contain a line break or NUL")
+	}
+
+	return c.server.write(line + "\r\n")
+}
+
+//the commands every client knows, the args are in the order a user would type them
+func builtinCommands() []CommandSpec {
+	return []CommandSpec{
+		{Name: "join", MinArgs: 1, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return joinMessages(joinArgs(args)), nil
+		}},
+		{Name: "part", MinArgs: 1, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("PART", args[0]).Text(strings.Join(args[1:], " "))}, nil
+		}},
+		{Name: "list", MinArgs: 0, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("LIST", scope(args)...)}, nil
+		}},
+		{Name: "names", MinArgs: 0, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("NAMES", scope(args)...)}, nil
+		}},
+		{Name: "invite", MinArgs: 2, MaxArgs: 2, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("INVITE", args[0], args[1])}, nil
+		}},
+		// kick <nick> <channel> [reason]
+		{Name: "kick", MinArgs: 2, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("KICK", args[1], args[0]).Text(strings.Join(args[2:], " "))}, nil
+		}},
+		{Name: "topic", MinArgs: 1, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			if len(args) == 1 {
+				return []*Message{NewMessage("TOPIC", args[0])}, nil
+			}
+
+			return []*Message{NewMessage("TOPIC", args[0]).Text(strings.Join(args[1:], " "))}, nil
+		}},
+		{Name: "mode", MinArgs: 1, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("MODE", args...)}, nil
+		}},
+		{Name: "nick", MinArgs: 1, MaxArgs: 1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("NICK", args[0])}, nil
+		}},
+		{Name: "msg", MinArgs: 2, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("PRIVMSG", args[0]).Text(strings.Join(args[1:], " "))}, nil
+		}},
+		{Name: "notice", MinArgs: 2, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("NOTICE", args[0]).Text(strings.Join(args[1:], " "))}, nil
+		}},
+		{Name: "away", MinArgs: 0, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			if len(args) == 0 {
+				return []*Message{NewMessage("AWAY")}, nil
+			}
+
+			return []*Message{NewMessage("AWAY").Text(strings.Join(args, " "))}, nil
+		}},
+		{Name: "who", MinArgs: 1, MaxArgs: 2, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("WHO", args...)}, nil
+		}},
+		{Name: "whois", MinArgs: 1, MaxArgs: 2, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("WHOIS", args...)}, nil
+		}},
+		{Name: "oper", MinArgs: 2, MaxArgs: 2, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("OPER", args[0], args[1])}, nil
+		}},
+		{Name: "kill", MinArgs: 1, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("KILL", args[0]).Text(strings.Join(args[1:], " "))}, nil
+		}},
+		{Name: "wallops", MinArgs: 1, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("WALLOPS").Text(strings.Join(args, " "))}, nil
+		}},
+		{Name: "samode", MinArgs: 2, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("SAMODE", args...)}, nil
+		}},
+		{Name: "stats", MinArgs: 1, MaxArgs: 2, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("STATS", args...)}, nil
+		}},
+		{Name: "quit", MinArgs: 0, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			return []*Message{NewMessage("QUIT").Text(strings.Join(args, " "))}, nil
+		}},
+		// raw <command> [params] [:text], the way the line would be typed
+		{Name: "raw", MinArgs: 1, MaxArgs: -1, Encode: func(args []string) ([]*Message, error) {
+			message := NewMessage(args[0])
+			for index, arg := range args[1:] {
+				if strings.HasPrefix(arg, ":") {
+					message.Text(strings.TrimPrefix(strings.Join(args[index+1:], " "), ":"))
+					break
+				}
+				message.Param(arg)
+			}
+
+			return []*Message{message}, nil
+		}},
+	}
+}
+
+//LIST and NAMES take a comma separated list of channels
+func scope(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	return []string{strings.Join(args, ",")}
+}
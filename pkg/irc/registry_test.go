@@ -0,0 +1,86 @@
+package irc
+
+import (
+	"strings"
+	"testing"
+)
+
+//every built in command refuses too few or too many args instead of indexing past them
+func TestCommandArity(t *testing.T) {
+	args := func(count int) []string {
+		list := make([]string, count)
+		for index := range list {
+			list[index] = "#go"
+		}
+		return list
+	}
+
+	for _, spec := range builtinCommands() {
+		lines := func(args []string) (lines []string, err error) {
+			defer func() {
+				if recovered := recover(); recovered != nil {
+					t.Errorf("%s with %d args panicked: %v", spec.Name, len(args), recovered)
+				}
+			}()
+
+			return spec.lines(args)
+		}
+
+		if spec.MinArgs > 0 {
+			if _, err := lines(nil); err == nil {
+				t.Errorf("%s accepted nil args", spec.Name)
+			}
+		}
+		for count := 0; count < spec.MinArgs; count++ {
+			if _, err := lines(args(count)); err == nil {
+				t.Errorf("%s accepted %d args, it needs %d", spec.Name, count, spec.MinArgs)
+			}
+		}
+		if spec.MaxArgs >= 0 {
+			if _, err := lines(args(spec.MaxArgs + 1)); err == nil {
+				t.Errorf("%s accepted %d args, it takes at most %d", spec.Name, spec.MaxArgs+1, spec.MaxArgs)
+			}
+		}
+
+		// the fewest args it takes are enough to encode it
+		lines(args(spec.MinArgs))
+	}
+}
+
+func TestCommandLines(t *testing.T) {
+	registry := newCommandRegistry()
+
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+	}{
+		{"invite", []string{"joe"}, "", "at least 2"},
+		{"invite", []string{"joe", "#go", "extra"}, "", "at most 2"},
+		{"invite", []string{"joe", "#go"}, "INVITE joe #go\r\n", ""},
+		{"kick", []string{"joe"}, "", "at least 2"},
+		{"kick", []string{"joe", "#go", "be", "nice"}, "KICK #go joe :be nice\r\n", ""},
+		{"part", nil, "", "at least 1"},
+		{"topic", []string{"#go"}, "TOPIC #go\r\n", ""},
+	}
+
+	for _, test := range tests {
+		spec, ok := registry.get(test.name)
+		if !ok {
+			t.Fatalf("%s isn't registered", test.name)
+		}
+
+		lines, err := spec.lines(test.args)
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s %v: got the error %v, want one containing %q", test.name, test.args, err, test.wantErr)
+			}
+			continue
+		}
+
+		if err != nil || strings.Join(lines, "") != test.want {
+			t.Errorf("%s %v = %q, %v, want %q", test.name, test.args, lines, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,318 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//SentMessage a message as the server accepted it. Echoed is false when the server didn't send the message
+//back to us, in that case MsgID is empty and Time is the local time it was sent
+type SentMessage struct {
+	Target     string
+	Message    string
+	MsgID      string
+	Time       time.Time
+	ServerTime bool
+	Echoed     bool
+}
+
+//ReplyError an error numeric the server replied with, e.g. ERR_CANNOTSENDTOCHAN
+type ReplyError struct {
+	Code     int32
+	CodeName string
+	Target   string
+	Message  string
+}
+
+func (e *ReplyError) Error() string {
+	if len(e.Target) > 0 {
+		return e.Target + ": " + e.Message
+	}
+
+	return e.Message
+}
+
+//SendResult resolves once the server accepted or rejected a message sent with SendMessage
+type SendResult struct {
+	once sync.Once
+	done chan struct{}
+	sent SentMessage
+	err  error
+}
+
+func newSendResult() *SendResult {
+	return &SendResult{
+		done: make(chan struct{}),
+	}
+}
+
+func (r *SendResult) resolve(sent SentMessage, err error) {
+	r.once.Do(func() {
+		r.sent = sent
+		r.err = err
+		close(r.done)
+	})
+}
+
+//Done is closed once the message was accepted or rejected
+func (r *SendResult) Done() <-chan struct{} {
+	return r.done
+}
+
+//Wait blocks until the message was accepted or rejected, or the ctx is done
+func (r *SendResult) Wait(ctx context.Context) (SentMessage, error) {
+	select {
+	case <-r.done:
+		return r.sent, r.err
+	case <-ctx.Done():
+		return SentMessage{}, ctx.Err()
+	}
+}
+
+type pendingSend struct {
+	label  string
+	target string
+	text   string
+	result *SendResult
+}
+
+//SendMessage sends a PRIVMSG to the target. With labeled-response or echo-message enabled the result resolves
+//with the message the server echoed back or the error numeric it replied with, otherwise it resolves as
+//soon as the message was written
+func (c *Client) SendMessage(target, message string) *SendResult {
+	result := newSendResult()
+	if !c.server.isRunning() {
+		result.resolve(SentMessage{}, errors.New("Not connected to a server"))
+		return result
+	}
+
+	// nothing is sent or left waiting for a message that can't be encoded, e.g. one with a line break
+	if _, err := NewMessage("PRIVMSG", target).Text(message).Encode(); err != nil {
+		result.resolve(SentMessage{}, err)
+		return result
+	}
+
+	pending := &pendingSend{
+		target: target,
+		text:   message,
+		result: result,
+	}
+
+	switch {
+	case c.HasCapability("labeled-response"):
+		c.mu.Lock()
+		c.labelID++
+		pending.label = "s" + strconv.FormatInt(int64(c.labelID), 36)
+		c.pendingSends = append(c.pendingSends, pending)
+		c.mu.Unlock()
+
+		if err := c.server.labeledPrivMessage(pending.label, target, message); err != nil {
+			c.dropPendingSend(pending)
+			result.resolve(SentMessage{}, err)
+		}
+
+	case c.HasCapability("echo-message"):
+		c.mu.Lock()
+		c.pendingSends = append(c.pendingSends, pending)
+		c.mu.Unlock()
+
+		if err := c.server.privMessage(target, message); err != nil {
+			c.dropPendingSend(pending)
+			result.resolve(SentMessage{}, err)
+		}
+
+	default:
+		if err := c.server.privMessage(target, message); err != nil {
+			result.resolve(SentMessage{}, err)
+		} else {
+			result.resolve(SentMessage{Target: target, Message: message, Time: time.Now()}, nil)
+		}
+	}
+
+	return result
+}
+
+//the error numerics the server answers a PRIVMSG with, other errors that name the same target are about
+//something else, e.g. ERR_NOSUCHNICK from a WHOIS
+var sendErrors = map[int32]bool{
+	ERR_NOSUCHNICK:       true,
+	ERR_NOSUCHSERVER:     true,
+	ERR_NOSUCHCHANNEL:    true,
+	ERR_CANNOTSENDTOCHAN: true,
+	ERR_TOOMANYTARGETS:   true,
+	ERR_NORECIPIENT:      true,
+	ERR_NOTEXTTOSEND:     true,
+	ERR_NOTOPLEVEL:       true,
+	ERR_WILDTOPLEVEL:     true,
+}
+
+//find and remove the pending send the line answers. Labeled lines only match their label, otherwise the
+//oldest send to the target that wasn't labeled matches. With matchText a send with the same text is
+//preferred, but servers that strip colors or trailing spaces echo altered text, so the oldest send to the
+//target matches when none has the same text
+func (c *Client) takePendingSend(line IncomingData, target, text string, matchText bool) *pendingSend {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	label, labeled := line.Tags["label"]
+	oldest := -1
+
+	for index, pending := range c.pendingSends {
+		if labeled {
+			if pending.label != label {
+				continue
+			}
+		} else if len(pending.label) > 0 || !strings.EqualFold(pending.target, target) {
+			continue
+		} else if matchText && pending.text != text {
+			if oldest == -1 {
+				oldest = index
+			}
+			continue
+		}
+
+		return c.removePendingSend(index)
+	}
+
+	if oldest != -1 {
+		return c.removePendingSend(oldest)
+	}
+
+	return nil
+}
+
+func (c *Client) removePendingSend(index int) *pendingSend {
+	pending := c.pendingSends[index]
+	c.pendingSends = append(c.pendingSends[:index], c.pendingSends[index+1:]...)
+
+	return pending
+}
+
+//resolve pending sends from echoed messages, ACK, labeled batches and error numerics. Returns true if the
+//line was the echo of our own message and shouldn't be passed on to the callbacks
+func (c *Client) resolveSends(line IncomingData) bool {
+	c.mu.Lock()
+	waiting := len(c.pendingSends) > 0
+	c.mu.Unlock()
+
+	if !waiting {
+		return false
+	}
+
+	switch {
+	case line.Code == RPL_PRIVMSG && len(line.Params) > 1:
+		if !strings.EqualFold(line.Nick, c.Nick()) {
+			return false
+		}
+
+		if pending := c.takePendingSend(line, line.Params[0], line.Params[1], true); pending != nil {
+			pending.result.resolve(sentFromLine(line), nil)
+			return true
+		}
+
+	case line.Code == RPL_ACK:
+		if pending := c.takePendingSend(line, "", "", false); pending != nil {
+			pending.result.resolve(SentMessage{Target: pending.target, Message: pending.text, Time: line.Time}, nil)
+			return true
+		}
+
+	case line.Code == RPL_BATCH:
+		if _, labeled := line.Tags["label"]; !labeled || line.Batch == nil {
+			return false
+		}
+
+		if pending := c.takePendingSend(line, "", "", false); pending != nil {
+			sent := SentMessage{Target: pending.target, Message: pending.text, Time: line.Time}
+			var err error
+
+			for _, child := range line.Batch.Messages {
+				if child.Code == RPL_PRIVMSG && len(child.Params) > 1 && !sent.Echoed {
+					sent = sentFromLine(child)
+				} else if replyErr := replyError(child); replyErr != nil && err == nil {
+					err = replyErr
+				}
+			}
+
+			pending.result.resolve(sent, err)
+		}
+
+	case replyError(line) != nil:
+		if _, labeled := line.Tags["label"]; !labeled && !sendErrors[line.Code] {
+			return false
+		}
+
+		target := ""
+		if len(line.Params) > 1 {
+			target = line.Params[1]
+		}
+
+		if pending := c.takePendingSend(line, target, "", false); pending != nil {
+			pending.result.resolve(SentMessage{}, replyError(line))
+		}
+	}
+
+	return false
+}
+
+//the send failed, it won't be answered
+func (c *Client) dropPendingSend(drop *pendingSend) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for index, pending := range c.pendingSends {
+		if pending == drop {
+			c.pendingSends = append(c.pendingSends[:index], c.pendingSends[index+1:]...)
+			return
+		}
+	}
+}
+
+//the sends that are still waiting won't be answered once the connection is gone
+func (c *Client) failPendingSends() {
+	c.mu.Lock()
+	pendingSends := c.pendingSends
+	c.pendingSends = nil
+	c.mu.Unlock()
+
+	for _, pending := range pendingSends {
+		pending.result.resolve(SentMessage{}, errors.New("Disconnected before the server answered"))
+	}
+}
+
+func sentFromLine(line IncomingData) SentMessage {
+	return SentMessage{
+		Target:     line.Params[0],
+		Message:    line.Params[1],
+		MsgID:      line.Tags["msgid"],
+		Time:       line.Time,
+		ServerTime: line.ServerTime,
+		Echoed:     true,
+	}
+}
+
+//returns the error numeric or FAIL reply as a ReplyError, nil for anything else
+func replyError(line IncomingData) *ReplyError {
+	if line.Code == RPL_FAIL {
+		return &ReplyError{Code: line.Code, CodeName: line.CodeName, Message: line.Message}
+	}
+
+	if line.Code < 400 || line.Code > 599 {
+		return nil
+	}
+
+	replyErr := &ReplyError{
+		Code:     line.Code,
+		CodeName: line.CodeName,
+		Message:  line.Message,
+	}
+	if len(line.Params) > 2 {
+		replyErr.Target = line.Params[1]
+		replyErr.Message = line.Params[len(line.Params)-1]
+	}
+
+	return replyErr
+}
@@ -0,0 +1,152 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+//wait for the send to resolve
+func sendResult(t *testing.T, result *SendResult) (SentMessage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	sent, err := result.Wait(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal("the send never resolved")
+	}
+
+	return sent, err
+}
+
+func TestSendMessageEcho(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	messages := make(chan EventType, 10)
+	c.HandleEventFunc(EventMessage, func(event EventType) {
+		messages <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.negotiate("echo-message")
+	server.register("me")
+
+	first := c.SendMessage("#go", "one")
+	second := c.SendMessage("#go", "two")
+	server.expect("PRIVMSG #go :one")
+	server.expect("PRIVMSG #go :two")
+
+	// echoes matching the text resolve their own send, whatever the order
+	server.send("@msgid=m2 :me!u@h PRIVMSG #go :two", "@msgid=m1 :me!u@h PRIVMSG #go :one")
+	if sent, err := sendResult(t, second); err != nil || sent.MsgID != "m2" || !sent.Echoed {
+		t.Errorf("the second send resolved with %+v, %v", sent, err)
+	}
+	if sent, err := sendResult(t, first); err != nil || sent.MsgID != "m1" {
+		t.Errorf("the first send resolved with %+v, %v", sent, err)
+	}
+
+	// the channel strips the formatting and the trailing space
+	stripped := c.SendMessage("#go", "\x02bold\x02 ")
+	server.expect("PRIVMSG #go :\x02bold\x02 ")
+	server.send("@msgid=m3 :me!u@h PRIVMSG #go :bold")
+	if sent, err := sendResult(t, stripped); err != nil || sent.Message != "bold" || sent.MsgID != "m3" {
+		t.Errorf("the stripped send resolved with %+v, %v", sent, err)
+	}
+
+	// our echoes aren't passed on as messages
+	server.send(":joe!u@h PRIVMSG #go :hi")
+	select {
+	case event := <-messages:
+		if event.Nick != "joe" {
+			t.Errorf("got the message %+v, want the one from joe", event)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("timed out waiting for joe's message")
+	}
+}
+
+func TestSendMessageErrors(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.negotiate("echo-message")
+	server.register("me")
+
+	result := c.SendMessage("#go", "hi")
+	server.expect("PRIVMSG #go :hi")
+
+	// an error that names the channel but isn't an answer to PRIVMSG
+	server.send(":srv 482 me #go :You're not channel operator")
+	select {
+	case <-result.Done():
+		t.Fatal("an unrelated error resolved the send")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	server.send(":srv 404 me #go :Cannot send to channel")
+	_, err := sendResult(t, result)
+
+	var replyErr *ReplyError
+	if !errors.As(err, &replyErr) || replyErr.Code != ERR_CANNOTSENDTOCHAN || replyErr.Target != "#go" {
+		t.Errorf("got the error %v, want ERR_CANNOTSENDTOCHAN", err)
+	}
+}
+
+func TestSendMessageLabeled(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.negotiate("labeled-response batch echo-message")
+	server.register("me")
+
+	label := func(line string) string {
+		tags, _, _ := strings.Cut(strings.TrimPrefix(line, "@"), " ")
+		return strings.TrimPrefix(tags, "label=")
+	}
+
+	// an ACK is the answer when there is nothing to echo
+	acked := c.SendMessage("joe", "hi")
+	server.send("@label=" + label(server.expect("@label=")) + " :srv ACK")
+	if sent, err := sendResult(t, acked); err != nil || sent.Echoed || sent.Target != "joe" || sent.Message != "hi" {
+		t.Errorf("the ACK resolved with %+v, %v", sent, err)
+	}
+
+	// the echo comes in a labeled batch
+	echoed := c.SendMessage("#go", "hi")
+	ref := label(server.expect("@label="))
+	server.send(
+		"@label="+ref+" :srv BATCH +b1 labeled-response",
+		"@batch=b1;msgid=m1 :me!u@h PRIVMSG #go :hi",
+		":srv BATCH -b1",
+	)
+	if sent, err := sendResult(t, echoed); err != nil || !sent.Echoed || sent.MsgID != "m1" {
+		t.Errorf("the labeled batch resolved with %+v, %v", sent, err)
+	}
+
+	// so does the error
+	failed := c.SendMessage("#secret", "hi")
+	ref = label(server.expect("@label="))
+	server.send(
+		"@label="+ref+" :srv BATCH +b2 labeled-response",
+		"@batch=b2 :srv 404 me #secret :Cannot send to channel",
+		":srv BATCH -b2",
+	)
+	var replyErr *ReplyError
+	if _, err := sendResult(t, failed); !errors.As(err, &replyErr) || replyErr.Code != ERR_CANNOTSENDTOCHAN {
+		t.Errorf("the labeled batch failed with %v, want ERR_CANNOTSENDTOCHAN", err)
+	}
+}
@@ -3,10 +3,9 @@ package irc
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
-	"fmt"
 	"net"
-	"strings"
 	"sync"
 	"time"
 )
@@ -16,18 +15,40 @@ type Server struct {
 	ServerName string
 	Port       int32
 	UseTSL     bool
-	running    bool
 
 	Timeout  time.Duration
 	PingFreq time.Duration
 
+	//mu guards running, dialing and conn, which are used from the callers' go routines as well as ours
+	mu      sync.Mutex
+	running bool
+	dialing bool
+	conn    net.Conn
+
 	readWriter *bufio.ReadWriter
-	conn       net.Conn
+	writeMu    sync.Mutex
+	//decode and encode convert lines from and to the character encoding of the network
+	decode func(string) string
+	encode func(string) string
+
+	//servers the pool to connect to, ServerName and Port are used when it is empty
+	servers   []ServerAddress
+	tlsConfig *tls.Config
+	proxy     *Proxy
+	//bindAddress and family choose the local address and the IP version of the connection
+	bindAddress string
+	family      string
+	//address the server we are connected to, lastGood and lastIP are tried first on the next connection
+	address  ServerAddress
+	lastGood ServerAddress
+	lastIP   string
+
+	redirectMu sync.Mutex
+	redirect   *ServerAddress
 
 	wg sync.WaitGroup
 	//TODO: these will neeed to be a custom struct to handle more data; make buffered
 	recvChan  chan IncomingData
-	sendChan  chan Command
 	errChan   chan error
 	pingChan  chan string
 	closeChan chan struct{}
@@ -39,51 +60,81 @@ func NewIRCServer(server string, useTLS bool) Server {
 		ServerName: server,
 		Port:       6667,
 		UseTSL:     useTLS,
-		running:    false,
 
 		Timeout:  time.Minute * 3,
 		PingFreq: time.Minute * 2,
 
 		recvChan:  make(chan IncomingData),
-		sendChan:  make(chan Command),
 		errChan:   make(chan error),
 		pingChan:  make(chan string),
 		closeChan: make(chan struct{}),
 	}
 }
 
-//start will make the initial irc connection and start the needed go routines if no errors occured
+//start will make the initial irc connection and start the needed go routines if no errors occured. Dialing
+//stops when the ctx is done
 func (s *Server) start(ctx context.Context, username, password string) error {
-	if !s.running {
-		s.running = true
-
-		dialer := net.Dialer{
-			Timeout: s.Timeout,
-		}
+	s.mu.Lock()
+	if s.running || s.dialing {
+		s.mu.Unlock()
+		return errors.New("Calling start on a running server")
+	}
+	s.dialing = true
+	s.mu.Unlock()
 
-		conn, err := dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", s.ServerName, s.Port))
-		if err != nil {
-			s.running = false
-			return err
-		}
+	conn, err := s.dial(ctx)
 
-		s.conn = conn
-		s.readWriter = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	s.mu.Lock()
+	s.dialing = false
+	if err == nil && ctx.Err() != nil {
+		// stopped while the connection was being made
+		conn.Close()
+		err = ctx.Err()
+	}
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
 
-		if len(password) > 1 {
-			s.pass(password)
-		}
-		s.user(username)
+	s.writeMu.Lock()
+	s.readWriter = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+	s.writeMu.Unlock()
 
-		s.wg.Add(3)
-		go s.recv(ctx)
-		go s.send(ctx)
-		go s.sendPingResponse(ctx)
+	// added under the lock so close only waits for go routines that were started
+	s.wg.Add(2)
+	s.conn = conn
+	s.running = true
+	s.mu.Unlock()
 
-		return nil
+	// registration waits for CAP END once the server sees CAP LS, servers without CAP ignore it
+	s.capability("LS 302")
+	if len(password) > 1 {
+		s.pass(password)
 	}
+	s.user(username)
+
+	go s.recv(ctx)
+	go s.sendPingResponse(ctx)
+
+	return nil
+}
+
+//isRunning is true while we are connected
+func (s *Server) isRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return errors.New("Calling start on a running server")
+	return s.running
+}
+
+//disconnect ends the connection without waiting, recv sees the error and closes it
+func (s *Server) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		s.conn.SetReadDeadline(time.Now())
+	}
 }
 
 //block waiting to receive anything from the connected irc server, if a I/O error happens, the connection
@@ -94,16 +145,25 @@ func (s *Server) recv(ctx context.Context) {
 	for {
 		data, err := s.readWriter.ReadString('\n')
 		if err != nil {
-			s.errChan <- err
-			s.closeChan <- struct{}{}
-
+			s.mu.Lock()
 			if s.running {
 				s.running = false
 				s.conn.Close()
 			}
+			s.mu.Unlock()
+
+			// a redirect closed the connection on purpose
+			if !s.redirecting() {
+				s.errChan <- err
+			}
+			s.closeChan <- struct{}{}
 			break
 		}
 
+		if s.decode != nil {
+			data = s.decode(data)
+		}
+
 		if recData, ok := parseRawInput(data); ok {
 			s.recvChan <- recData
 		}
@@ -112,34 +172,6 @@ func (s *Server) recv(ctx context.Context) {
 	<-ctx.Done()
 }
 
-//send will send user commands to the connected irc server
-func (s *Server) send(ctx context.Context) {
-	defer s.wg.Done()
-
-	for {
-		select {
-		case command := <-s.sendChan:
-			switch strings.ToLower(command.Action) {
-			case "join":
-				s.join(command.Args...)
-			case "list":
-				s.list(command.Args...)
-			case "names":
-				s.name(command.Args...)
-			case "invite":
-				s.invite(command.Args[0], command.Args[1])
-			case "kick":
-				s.kick(command.Args[0], command.Args[1], strings.Join(command.Args[2:], " "))
-			case "part":
-				s.part(command.Args[0], strings.Join(command.Args[1:], " "))
-			}
-
-		case <-ctx.Done():
-			return
-		}
-	}
-}
-
 //send our automatic ping/pong responses
 func (s *Server) sendPingResponse(ctx context.Context) {
 	ticker := time.NewTicker(s.PingFreq)
@@ -149,7 +181,13 @@ func (s *Server) sendPingResponse(ctx context.Context) {
 		select {
 		case <-ticker.C:
 			s.ping()
-			s.pingChan <- "PONG response to PING"
+
+			select {
+			case s.pingChan <- "PONG response to PING":
+			case <-ctx.Done():
+				ticker.Stop()
+				return
+			}
 
 		case <-ctx.Done():
 			ticker.Stop()
@@ -158,16 +196,15 @@ func (s *Server) sendPingResponse(ctx context.Context) {
 	}
 }
 
-//close will closed the server connection and close the active go routines
+//close will closed the server connection and wait for the active go routines. It is safe to call while
+//not connected, and the channels stay open so the server can be started again
 func (s *Server) close() {
-	if s.running {
-		s.running = false
-		s.conn.SetReadDeadline(time.Now())
-	}
+	s.mu.Lock()
+	running := s.running
+	s.mu.Unlock()
 
-	s.wg.Wait()
-	close(s.pingChan)
-	close(s.recvChan)
-	close(s.errChan)
-	close(s.closeChan)
+	if running {
+		s.disconnect()
+		s.wg.Wait()
+	}
 }
@@ -0,0 +1,117 @@
+package irc
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+//testServer a fake irc server that accepts one connection at a time
+type testServer struct {
+	t        *testing.T
+	listener net.Listener
+	conn     net.Conn
+	lines    chan string
+}
+
+func newTestServer(t *testing.T) *testServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	return &testServer{t: t, listener: listener}
+}
+
+func (s *testServer) address() ServerAddress {
+	return ServerAddress{Host: "127.0.0.1", Port: int32(s.listener.Addr().(*net.TCPAddr).Port)}
+}
+
+//accept waits for the client to connect and starts reading its lines
+func (s *testServer) accept() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	s.t.Cleanup(func() { conn.Close() })
+
+	s.conn = conn
+	s.lines = make(chan string, 100)
+	go func(lines chan string) {
+		defer close(lines)
+
+		reader := bufio.NewReader(conn)
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				return
+			}
+			lines <- strings.TrimRight(line, "\r\n")
+		}
+	}(s.lines)
+}
+
+func (s *testServer) send(lines ...string) {
+	for _, line := range lines {
+		if _, err := s.conn.Write([]byte(line + "\r\n")); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+}
+
+//register sends the welcome and the end of the MOTD
+func (s *testServer) register(nick string) {
+	s.send(":srv 001 "+nick+" :Welcome", ":srv 376 "+nick+" :End of MOTD")
+}
+
+//negotiate offers the capabilities and acknowledges the client's request for them
+func (s *testServer) negotiate(capabilities string) {
+	s.expect("CAP LS")
+	s.send(":srv CAP * LS :" + capabilities)
+	s.expect("CAP REQ")
+	s.send(":srv CAP * ACK :" + capabilities)
+	s.expect("CAP END")
+}
+
+//expect waits for a line from the client starting with prefix
+func (s *testServer) expect(prefix string) string {
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case line, ok := <-s.lines:
+			if !ok {
+				s.t.Fatalf("the client disconnected while waiting for %q", prefix)
+			}
+			if strings.HasPrefix(line, prefix) {
+				return line
+			}
+
+		case <-timeout:
+			s.t.Fatalf("timed out waiting for %q", prefix)
+		}
+	}
+}
+
+//eventually waits for the client to catch up with the lines the server sent
+func eventually(t *testing.T, what string, done func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for !done() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+//testClient a client for the server that doesn't poll presence
+func testClient(servers ...ServerAddress) *Client {
+	c := NewClient("me", "", "unused.invalid")
+	c.PresenceFreq = 0
+	c.Servers = servers
+	return c
+}
@@ -0,0 +1,326 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+//ServicesPatterns the NickServ notices that tell us if identifying worked. A notice matches a pattern
+//if it contains it, ignoring case
+type ServicesPatterns struct {
+	Success []string
+	Failure []string
+}
+
+//AnopePatterns the replies of Anope's NickServ
+var AnopePatterns = ServicesPatterns{
+	Success: []string{"Password accepted", "You are now identified"},
+	Failure: []string{"Password incorrect", "isn't registered", "Access denied"},
+}
+
+//AthemePatterns the replies of Atheme's NickServ
+var AthemePatterns = ServicesPatterns{
+	Success: []string{"You are now identified for", "You are already logged in"},
+	Failure: []string{"Invalid password for", "is not registered", "You have been locked out"},
+}
+
+//the ways NickServ can take our nick back from whoever is using it
+const (
+	//RegainGhost disconnects the user holding our nick, we change to it ourselves
+	RegainGhost = "GHOST"
+	//RegainRegain disconnects the user holding our nick and changes us to it
+	RegainRegain = "REGAIN"
+	//RegainRecover is the older form of REGAIN
+	RegainRecover = "RECOVER"
+)
+
+//Services talks to NickServ and ChanServ for networks without SASL
+type Services struct {
+	NickServ string
+	ChanServ string
+	//Account the account to identify to, empty uses our primary nick
+	Account  string
+	Password string
+	Patterns ServicesPatterns
+	//RegainMethod is used when our primary nick is taken, empty leaves us on the alternate nick
+	RegainMethod string
+	//Timeout how long to wait for services to answer
+	Timeout time.Duration
+	//RecoverBans asks ChanServ to unban or invite us when a join fails with ERR_BANNEDFROMCHAN or
+	//ERR_INVITEONLYCHAN and joins again
+	RecoverBans bool
+
+	client     *Client
+	mu         sync.Mutex
+	recovering map[string]bool
+}
+
+//NewServices a services helper for the client that understands both Anope and Atheme
+func NewServices(client *Client, password string) *Services {
+	return &Services{
+		NickServ: "NickServ",
+		ChanServ: "ChanServ",
+		Password: password,
+		Patterns: ServicesPatterns{
+			Success: append(append([]string{}, AnopePatterns.Success...), AthemePatterns.Success...),
+			Failure: append(append([]string{}, AnopePatterns.Failure...), AthemePatterns.Failure...),
+		},
+		RegainMethod: RegainGhost,
+		Timeout:      time.Second * 30,
+		client:       client,
+	}
+}
+
+//Action the RegisterAction that identifies, regains our primary nick if it was taken and starts
+//watching for bans. Add it to the client's OnRegister
+func (s *Services) Action() RegisterAction {
+	return func(ctx context.Context, c *Client) error {
+		if s.RecoverBans {
+			s.watchJoins()
+		}
+
+		if err := s.Identify(ctx); err != nil {
+			return err
+		}
+
+		if len(s.RegainMethod) > 0 && !strings.EqualFold(c.Nick(), c.UserName) {
+			return s.Regain(ctx)
+		}
+
+		return nil
+	}
+}
+
+//Identify identifies with NickServ and waits for it to tell us if the password was accepted
+func (s *Services) Identify(ctx context.Context) error {
+	c := s.client
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	wait, remove := c.expect(func(line IncomingData) bool {
+		return line.Code == RPL_LOGGEDIN || (s.fromNickServ(line) && (s.matches(line, s.Patterns.Success) || s.matches(line, s.Patterns.Failure)))
+	})
+
+	// on an alternate nick NickServ needs to be told the account
+	account := s.Account
+	if len(account) == 0 && !strings.EqualFold(c.Nick(), c.UserName) {
+		account = c.UserName
+	}
+
+	identify := "IDENTIFY " + s.Password
+	if len(account) > 0 {
+		identify = "IDENTIFY " + account + " " + s.Password
+	}
+	if err := c.server.privMessage(s.NickServ, identify); err != nil {
+		remove()
+		return err
+	}
+
+	line, err := s.wait(ctx, wait)
+	if err != nil {
+		return err
+	}
+	if line.Code != RPL_LOGGEDIN && s.matches(line, s.Patterns.Failure) {
+		return errors.New(line.Message)
+	}
+
+	return nil
+}
+
+//Regain takes our primary nick back from whoever is using it, using the RegainMethod
+func (s *Services) Regain(ctx context.Context) error {
+	c := s.client
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	primary := c.UserName
+	if strings.EqualFold(c.Nick(), primary) {
+		return nil
+	}
+
+	method := s.RegainMethod
+	if len(method) == 0 {
+		method = RegainGhost
+	}
+
+	changed, removeChanged := c.expect(func(line IncomingData) bool {
+		switch line.Code {
+		case RPL_NICKCHANGE:
+			return strings.EqualFold(line.Nick, c.Nick()) && strings.EqualFold(line.Target, primary)
+		case ERR_NICKNAMEINUSE, ERR_UNAVAILRESOURCE:
+			return len(line.Params) > 2 && strings.EqualFold(line.Params[1], primary)
+		case RPL_NOTICE:
+			return s.fromNickServ(line) && s.matches(line, s.Patterns.Failure)
+		}
+
+		return false
+	})
+
+	if method == RegainGhost {
+		// the nick is only free once NickServ answered the GHOST
+		answered, removeAnswered := c.expect(s.fromNickServ)
+		if err := c.server.privMessage(s.NickServ, method+" "+primary+" "+s.Password); err != nil {
+			removeAnswered()
+			removeChanged()
+			return err
+		}
+
+		line, err := s.wait(ctx, answered)
+		if err != nil {
+			removeChanged()
+			return err
+		}
+		if s.matches(line, s.Patterns.Failure) {
+			removeChanged()
+			return errors.New(line.Message)
+		}
+
+		c.server.nick(primary)
+	} else if err := c.server.privMessage(s.NickServ, method+" "+primary+" "+s.Password); err != nil {
+		removeChanged()
+		return err
+	}
+
+	line, err := s.wait(ctx, changed)
+	if err != nil {
+		return err
+	}
+	if line.Code != RPL_NICKCHANGE {
+		return errors.New(line.Message)
+	}
+
+	return nil
+}
+
+//Op asks ChanServ to op us in the room
+func (s *Services) Op(room string) error {
+	return s.chanServ("OP", room)
+}
+
+//Invite asks ChanServ to invite us to the room, for channels that are invite only
+func (s *Services) Invite(room string) error {
+	return s.chanServ("INVITE", room)
+}
+
+//Unban asks ChanServ to remove the bans that match us in the room
+func (s *Services) Unban(room string) error {
+	return s.chanServ("UNBAN", room)
+}
+
+func (s *Services) chanServ(command, room string) error {
+	if !s.client.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+
+	return s.client.server.privMessage(s.ChanServ, command+" "+room)
+}
+
+//rejoin channels we are banned from or need an invite for with the help of ChanServ, each channel is only
+//tried once until we get in. The hook goes away with the connection, not when the register action is done
+func (s *Services) watchJoins() {
+	c := s.client
+
+	c.mu.Lock()
+	ctx := c.connCtx
+	c.mu.Unlock()
+
+	s.mu.Lock()
+	s.recovering = make(map[string]bool)
+	s.mu.Unlock()
+
+	remove := c.addHook(func(line IncomingData) bool {
+		switch line.Code {
+		case RPL_ROOMJOIN:
+			if strings.EqualFold(line.Nick, c.Nick()) {
+				s.mu.Lock()
+				delete(s.recovering, strings.ToLower(line.Room))
+				s.mu.Unlock()
+			}
+
+		case ERR_BANNEDFROMCHAN, ERR_INVITEONLYCHAN:
+			room := strings.ToLower(line.Room)
+			if len(room) == 0 {
+				return false
+			}
+
+			s.mu.Lock()
+			tried := s.recovering[room]
+			s.recovering[room] = true
+			s.mu.Unlock()
+
+			if !tried {
+				go s.recoverChannel(ctx, line.Room, line.Code)
+			}
+		}
+
+		return false
+	})
+
+	go func() {
+		<-ctx.Done()
+		remove()
+	}()
+}
+
+//ask ChanServ to let us in and join again once it answered
+func (s *Services) recoverChannel(ctx context.Context, room string, code int32) {
+	c := s.client
+
+	answered, remove := c.expect(func(line IncomingData) bool {
+		return line.Code == RPL_NOTICE && strings.EqualFold(line.Nick, s.ChanServ)
+	})
+
+	ask := s.Invite
+	if code == ERR_BANNEDFROMCHAN {
+		ask = s.Unban
+	}
+	if err := ask(room); err != nil {
+		remove()
+		return
+	}
+
+	if _, err := s.wait(ctx, answered); err != nil && ctx.Err() != nil {
+		return
+	}
+
+	c.server.join(room)
+}
+
+//wait for the expected line, giving up after the Timeout
+func (s *Services) wait(ctx context.Context, wait func(context.Context) (IncomingData, error)) (IncomingData, error) {
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = time.Second * 30
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	line, err := wait(waitCtx)
+	if err != nil && ctx.Err() == nil {
+		return line, errors.New("Services didn't answer in time")
+	}
+
+	return line, err
+}
+
+func (s *Services) fromNickServ(line IncomingData) bool {
+	return line.Code == RPL_NOTICE && strings.EqualFold(line.Nick, s.NickServ)
+}
+
+func (s *Services) matches(line IncomingData, patterns []string) bool {
+	message := strings.ToLower(line.Message)
+
+	for _, pattern := range patterns {
+		if len(pattern) > 0 && strings.Contains(message, strings.ToLower(pattern)) {
+			return true
+		}
+	}
+
+	return false
+}
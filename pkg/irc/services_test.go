@@ -0,0 +1,116 @@
+package irc
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServicesIdentify(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+	services := NewServices(c, "pw")
+	services.Timeout = 300 * time.Millisecond
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.expect("USER")
+	server.register("me")
+
+	tests := []struct {
+		name    string
+		reply   string
+		wantErr string
+	}{
+		{name: "anope", reply: ":NickServ!s@services NOTICE me :Password accepted - you are now recognized."},
+		{name: "atheme", reply: ":NickServ!s@services NOTICE me :You are now identified for \x02me\x02."},
+		{name: "logged in", reply: ":srv 900 me me!u@h me :You are now logged in as me"},
+		{name: "anope failure", reply: ":NickServ!s@services NOTICE me :Password incorrect.", wantErr: "Password incorrect"},
+		{name: "atheme failure", reply: ":NickServ!s@services NOTICE me :Invalid password for \x02me\x02.", wantErr: "Invalid password"},
+		// a notice from someone else isn't an answer
+		{name: "no answer", reply: ":joe!u@h NOTICE me :Password accepted", wantErr: "didn't answer"},
+	}
+
+	for _, test := range tests {
+		errs := make(chan error, 1)
+		go func() {
+			errs <- services.Identify(context.Background())
+		}()
+
+		server.expect("PRIVMSG NickServ :IDENTIFY pw")
+		server.send(test.reply)
+
+		err := <-errs
+		if len(test.wantErr) > 0 {
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("%s: got the error %v, want one containing %q", test.name, err, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		}
+	}
+}
+
+func TestServicesAction(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+	c.AltNicks = []string{"alt"}
+
+	services := NewServices(c, "pw")
+	services.RecoverBans = true
+	c.OnRegister = []RegisterAction{services.Action()}
+
+	errs := make(chan EventType, 5)
+	c.HandleEventFunc(EventError, func(event EventType) {
+		errs <- event
+	})
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.expect("NICK me")
+	server.send(":srv 433 * me :Nickname is already in use")
+	server.expect("NICK alt")
+	server.register("alt")
+
+	// on the alternate nick NickServ is told the account, then the ghost is killed and the nick taken back
+	server.expect("PRIVMSG NickServ :IDENTIFY me pw")
+	server.send(":NickServ!s@services NOTICE alt :Password accepted - you are now recognized.")
+	server.expect("PRIVMSG NickServ :GHOST me pw")
+	server.send(":NickServ!s@services NOTICE alt :Ghost with your nick has been killed.")
+	server.expect("NICK me")
+	server.send(":alt!u@h NICK :me")
+	eventually(t, "the regained nick", func() bool { return c.Nick() == "me" })
+
+	tests := []struct {
+		line string
+		ask  string
+	}{
+		{":srv 474 me #go :Cannot join channel (+b)", "PRIVMSG ChanServ :UNBAN #go"},
+		{":srv 473 me #secret :Cannot join channel (+i)", "PRIVMSG ChanServ :INVITE #secret"},
+	}
+
+	for _, test := range tests {
+		room := strings.Fields(test.line)[3]
+
+		server.send(test.line)
+		server.expect(test.ask)
+		server.send(":ChanServ!s@services NOTICE me :Done")
+		if line := server.expect("JOIN"); line != "JOIN "+room {
+			t.Errorf("%s: sent %q", test.line, line)
+		}
+	}
+
+	// the join failures are errors of their own, the register action shouldn't have failed
+	for len(errs) > 0 {
+		if event := <-errs; strings.HasPrefix(event.Message, "Register action") {
+			t.Errorf("unexpected error %q", event.Message)
+		}
+	}
+}
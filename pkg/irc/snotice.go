@@ -0,0 +1,156 @@
+package irc
+
+import (
+	"net"
+	"strings"
+)
+
+//the kinds of server notices that are parsed into their fields, anything else is ServerNoticeOther
+const (
+	ServerNoticeConnect = "CONNECT"
+	ServerNoticeExit    = "EXIT"
+	ServerNoticeOther   = "OTHER"
+)
+
+//ServerNotice a notice from the server, e.g. the client connects and exits opers see with a snomask.
+//Message is the text without the "*** Notice --" prefix, the other fields are only set when the server
+//sent them
+type ServerNotice struct {
+	Kind     string
+	Nick     string
+	User     string
+	Host     string
+	IP       string
+	Class    string
+	RealName string
+	Reason   string
+	Message  string
+}
+
+//a server notice comes from the server itself and starts with ***
+func isServerNotice(line IncomingData) bool {
+	return line.Code == RPL_NOTICE && len(line.Nick) == 0 && strings.HasPrefix(line.Message, "*** ")
+}
+
+//parse the notice formats of charybdis/solanum, UnrealIRCd and InspIRCd, e.g.
+//
+//	*** Notice -- Client connecting: nick (user@host) [ip] {class} [realname]
+//	*** Notice -- Client exiting: nick (user@host) [reason] [ip]
+//	*** CONNECT: Client connecting on port 6697 (class main): nick!user@host (ip) [realname]
+//	*** QUIT: Client exiting: nick!user@host (ip) [reason]
+func parseServerNotice(message string) *ServerNotice {
+	message = strings.TrimPrefix(message, "*** ")
+	message = strings.TrimPrefix(message, "Notice -- ")
+
+	notice := &ServerNotice{
+		Kind:    ServerNoticeOther,
+		Message: message,
+	}
+
+	rest := ""
+	if index := strings.Index(message, "Client connecting"); index != -1 {
+		notice.Kind = ServerNoticeConnect
+		rest = message[index+len("Client connecting"):]
+	} else if index := strings.Index(message, "Client exiting"); index != -1 {
+		notice.Kind = ServerNoticeExit
+		rest = message[index+len("Client exiting"):]
+	} else {
+		return notice
+	}
+
+	// InspIRCd puts the class before the client, "on port 6697 (class main): "
+	index := strings.Index(rest, ": ")
+	if index == -1 {
+		return notice
+	}
+	if class := strings.Index(rest[:index], "(class "); class != -1 {
+		notice.Class = strings.TrimSuffix(rest[class+len("(class "):index], ")")
+	}
+	rest = strings.TrimSpace(rest[index+2:])
+
+	client := rest
+	if index := strings.Index(rest, " "); index != -1 {
+		client, rest = rest[:index], rest[index+1:]
+	} else {
+		rest = ""
+	}
+
+	notice.Nick = client
+	if index := strings.Index(client, "!"); index != -1 {
+		notice.Nick = client[:index]
+		notice.User, notice.Host = splitUserHost(client[index+1:])
+	}
+
+	for _, group := range noticeGroups(rest) {
+		value := group[1 : len(group)-1]
+
+		switch group[0] {
+		case '(':
+			if strings.Contains(value, "@") {
+				notice.User, notice.Host = splitUserHost(value)
+			} else {
+				notice.IP = value
+			}
+
+		case '{':
+			notice.Class = value
+
+		case '[':
+			if len(notice.IP) == 0 && net.ParseIP(value) != nil {
+				notice.IP = value
+			} else if notice.Kind == ServerNoticeConnect && len(notice.RealName) == 0 {
+				notice.RealName = value
+			} else if notice.Kind == ServerNoticeExit && len(notice.Reason) == 0 {
+				notice.Reason = value
+			}
+		}
+	}
+
+	return notice
+}
+
+//split the (user@host) [ip] {class} groups, a group only ends at its closing bracket when the next group
+//or the end of the text follows, so a reason may contain brackets
+func noticeGroups(text string) []string {
+	closing := map[byte]byte{'(': ')', '[': ']', '{': '}'}
+	var groups []string
+
+	for len(text) > 0 {
+		text = strings.TrimLeft(text, " ")
+		if len(text) == 0 {
+			break
+		}
+
+		end, ok := closing[text[0]]
+		if !ok {
+			break
+		}
+
+		index := -1
+		for i := 1; i < len(text); i++ {
+			if text[i] != end {
+				continue
+			}
+			if i == len(text)-1 || (text[i+1] == ' ' && i+2 < len(text) && strings.IndexByte("([{", text[i+2]) != -1) {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			break
+		}
+
+		groups = append(groups, text[:index+1])
+		text = text[index+1:]
+	}
+
+	return groups
+}
+
+func splitUserHost(userHost string) (string, string) {
+	if index := strings.Index(userHost, "@"); index != -1 {
+		return userHost[:index], userHost[index+1:]
+	}
+
+	return userHost, ""
+}
@@ -0,0 +1,82 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseServerNotice(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+		want    ServerNotice
+	}{
+		{
+			"solanum connect",
+			"*** Notice -- Client connecting: joe (~joe@host.example) [192.0.2.1] {users} [Joe Example]",
+			ServerNotice{Kind: ServerNoticeConnect, Nick: "joe", User: "~joe", Host: "host.example", IP: "192.0.2.1", Class: "users", RealName: "Joe Example"},
+		},
+		{
+			"solanum exit",
+			"*** Notice -- Client exiting: joe (~joe@host.example) [Quit: bye [for now]] [192.0.2.1]",
+			ServerNotice{Kind: ServerNoticeExit, Nick: "joe", User: "~joe", Host: "host.example", IP: "192.0.2.1", Reason: "Quit: bye [for now]"},
+		},
+		{
+			"inspircd connect",
+			"*** CONNECT: Client connecting on port 6697 (class main): joe!joe@host.example (2001:db8::1) [Joe Example]",
+			ServerNotice{Kind: ServerNoticeConnect, Nick: "joe", User: "joe", Host: "host.example", IP: "2001:db8::1", Class: "main", RealName: "Joe Example"},
+		},
+		{
+			"inspircd exit",
+			"*** QUIT: Client exiting: joe!joe@host.example (2001:db8::1) [Ping timeout: 120 seconds]",
+			ServerNotice{Kind: ServerNoticeExit, Nick: "joe", User: "joe", Host: "host.example", IP: "2001:db8::1", Reason: "Ping timeout: 120 seconds"},
+		},
+		{
+			"other",
+			"*** Notice -- joe is now an operator",
+			ServerNotice{Kind: ServerNoticeOther},
+		},
+		{
+			"connect without fields",
+			"*** Notice -- Client connecting",
+			ServerNotice{Kind: ServerNoticeConnect},
+		},
+	}
+
+	for _, test := range tests {
+		got := parseServerNotice(test.message)
+		want := test.want
+		want.Message = got.Message
+
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("%s:\n got %+v\nwant %+v", test.name, *got, want)
+		}
+	}
+
+	if got := parseServerNotice("*** Notice -- joe is now an operator").Message; got != "joe is now an operator" {
+		t.Errorf("the message is %q, want the prefix removed", got)
+	}
+}
+
+func TestIsServerNotice(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{":irc.example NOTICE * :*** Notice -- Client connecting: joe (~joe@h) [192.0.2.1] {users} [Joe]", true},
+		// a user's notice is never a server notice
+		{":joe!u@h NOTICE me :*** Notice -- fake", false},
+		{":irc.example NOTICE me :hello", false},
+	}
+
+	for _, test := range tests {
+		line, ok := parseRawInput(test.line)
+		if !ok {
+			t.Fatalf("%q didn't parse", test.line)
+		}
+
+		if got := isServerNotice(line); got != test.want {
+			t.Errorf("isServerNotice(%q) = %v, want %v", test.line, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+package irc
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//User what we know about a user that shares a channel with us, Ident is the user part of nick!user@host
+type User struct {
+	Nick        string
+	Ident       string
+	Host        string
+	RealName    string
+	Account     string
+	Away        bool
+	AwayMessage string
+}
+
+//Member a user in a channel along with their channel prefixes, e.g. @+, and the modes they map to, e.g. ov
+type Member struct {
+	User
+	Prefix string
+	Modes  string
+}
+
+//Channel a snapshot of a channel we are in
+type Channel struct {
+	Name       string
+	Topic      string
+	TopicSetBy string
+	TopicTime  time.Time
+	Members    []Member
+}
+
+type channelState struct {
+	name   string
+	topic  string
+	topics []TopicChange
+	//lower case nick to the member prefixes
+	members map[string]string
+}
+
+//tracker keeps the state of the channels we are in and the users in them
+type tracker struct {
+	mu       sync.RWMutex
+	users    map[string]*User
+	channels map[string]*channelState
+}
+
+func newTracker() *tracker {
+	return &tracker{
+		users:    make(map[string]*User),
+		channels: make(map[string]*channelState),
+	}
+}
+
+//Channels the names of the channels we are in
+func (c *Client) Channels() []string {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+
+	names := make([]string, 0, len(c.state.channels))
+	for _, channel := range c.state.channels {
+		names = append(names, channel.name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+//Channel returns a snapshot of a channel we are in
+func (c *Client) Channel(name string) (Channel, bool) {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+
+	channel, ok := c.state.channels[strings.ToLower(name)]
+	if !ok {
+		return Channel{}, false
+	}
+
+	snapshot := Channel{
+		Name:    channel.name,
+		Topic:   channel.topic,
+		Members: make([]Member, 0, len(channel.members)),
+	}
+	if len(channel.topics) > 0 {
+		latest := channel.topics[len(channel.topics)-1]
+		snapshot.TopicSetBy = latest.SetBy
+		snapshot.TopicTime = latest.Time
+	}
+
+	modes, symbols := c.prefixes()
+	for nick, prefix := range channel.members {
+		if user, ok := c.state.users[nick]; ok {
+			snapshot.Members = append(snapshot.Members, Member{
+				User:   *user,
+				Prefix: prefix,
+				Modes:  prefixModes(prefix, modes, symbols),
+			})
+		}
+	}
+
+	sort.Slice(snapshot.Members, func(i, j int) bool {
+		return strings.ToLower(snapshot.Members[i].Nick) < strings.ToLower(snapshot.Members[j].Nick)
+	})
+
+	return snapshot, true
+}
+
+//User returns what we know about a user that shares a channel with us
+func (c *Client) User(nick string) (User, bool) {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+
+	if user, ok := c.state.users[strings.ToLower(nick)]; ok {
+		return *user, true
+	}
+
+	return User{}, false
+}
+
+//SetRealName changes our realname, this needs the server to support the setname capability
+func (c *Client) SetRealName(realName string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+	if !c.HasCapability("setname") {
+		return errors.New("The server doesn't support SETNAME")
+	}
+
+	return c.server.setName(realName)
+}
+
+//keep the channel state up to date with the line
+func (c *Client) track(line IncomingData) {
+	self := strings.EqualFold(line.Nick, c.Nick())
+
+	switch line.Code {
+	case RPL_ROOMJOIN:
+		c.state.join(line, self)
+	case RPL_ROOMPART:
+		c.state.part(line.Nick, line.Room, self)
+	case RPL_ROOMQUIT:
+		c.state.quit(line.Nick)
+	case RPL_ROOMKICK:
+		c.state.part(line.Target, line.Room, strings.EqualFold(line.Target, c.Nick()))
+	case RPL_ROOMTOPIC:
+		c.state.topic(line.Room, TopicChange{Topic: line.Message, SetBy: line.Nick, Time: line.Time})
+	case RPL_NOTOPIC:
+		c.state.topic(line.Room, TopicChange{Time: line.Time})
+	case RPL_TOPIC:
+		c.state.topic(line.Room, TopicChange{Topic: line.Message})
+	case RPL_TOPICWHOTIME:
+		c.state.topicWhoTime(line.Room, line.Target, line.Message)
+	case RPL_NICKCHANGE:
+		c.state.rename(line.Nick, line.Message)
+	case RPL_ACCOUNT:
+		c.state.account(line.Nick, line.Account)
+	case RPL_CHGHOST:
+		if len(line.Params) > 1 {
+			c.state.changeHost(line.Nick, line.Params[0], line.Params[1])
+		}
+	case RPL_SETNAME:
+		c.state.setName(line.Nick, line.RealName)
+	case RPL_BATCH:
+		c.trackBatch(line.Batch)
+	case RPL_NAMREPLY:
+		if names := c.parseNames(line); names != nil {
+			c.state.names(names)
+		}
+	}
+
+	// with account-tag every message from a user carries their account, no tag means they aren't logged in
+	if len(line.User) > 0 && line.Code != RPL_ACCOUNT && c.HasCapability("account-tag") {
+		c.state.account(line.Nick, line.Account)
+	}
+}
+
+//returns the user, adding it if we haven't seen it. The lock must be held
+func (t *tracker) user(nick string) *User {
+	key := strings.ToLower(nick)
+
+	user, ok := t.users[key]
+	if !ok {
+		user = &User{Nick: nick}
+		t.users[key] = user
+	}
+
+	return user
+}
+
+//update the user and host if the line had them. The lock must be held
+func (t *tracker) userFromLine(line IncomingData) *User {
+	user := t.user(line.Nick)
+	if len(line.User) > 0 {
+		user.Ident = line.User
+	}
+	if len(line.Host) > 0 {
+		user.Host = line.Host
+	}
+
+	return user
+}
+
+//drop the user once we no longer share a channel with them. The lock must be held
+func (t *tracker) forget(nick string) {
+	key := strings.ToLower(nick)
+
+	for _, channel := range t.channels {
+		if _, ok := channel.members[key]; ok {
+			return
+		}
+	}
+
+	delete(t.users, key)
+}
+
+func (t *tracker) join(line IncomingData, self bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := strings.ToLower(line.Room)
+	if self {
+		t.channels[key] = &channelState{
+			name:    line.Room,
+			members: make(map[string]string),
+		}
+	}
+
+	channel, ok := t.channels[key]
+	if !ok {
+		return
+	}
+
+	user := t.userFromLine(line)
+	channel.members[strings.ToLower(line.Nick)] = ""
+
+	// extended-join sends the account and realname, account-tag only the account
+	if len(line.RealName) > 0 || len(line.Account) > 0 {
+		user.Account = line.Account
+	}
+	if len(line.RealName) > 0 {
+		user.RealName = line.RealName
+	}
+}
+
+func (t *tracker) part(nick, room string, self bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := strings.ToLower(room)
+	channel, ok := t.channels[key]
+	if !ok {
+		return
+	}
+
+	if self {
+		delete(t.channels, key)
+		for member := range channel.members {
+			t.forget(member)
+		}
+		return
+	}
+
+	delete(channel.members, strings.ToLower(nick))
+	t.forget(nick)
+}
+
+func (t *tracker) quit(nick string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := strings.ToLower(nick)
+	for _, channel := range t.channels {
+		delete(channel.members, key)
+	}
+	delete(t.users, key)
+}
+
+func (t *tracker) rename(oldNick, newNick string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	oldKey, newKey := strings.ToLower(oldNick), strings.ToLower(newNick)
+	user, ok := t.users[oldKey]
+	if !ok {
+		return
+	}
+
+	delete(t.users, oldKey)
+	user.Nick = newNick
+	t.users[newKey] = user
+
+	for _, channel := range t.channels {
+		if prefix, ok := channel.members[oldKey]; ok {
+			delete(channel.members, oldKey)
+			channel.members[newKey] = prefix
+		}
+	}
+}
+
+//add the members from a RPL_NAMREPLY
+func (t *tracker) names(names *NamesReply) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	channel, ok := t.channels[strings.ToLower(names.Channel)]
+	if !ok {
+		return
+	}
+
+	for _, member := range names.Members {
+		user := t.user(member.Nick)
+		if len(member.Host) > 0 {
+			user.Ident = member.Ident
+			user.Host = member.Host
+		}
+		channel.members[strings.ToLower(member.Nick)] = member.Prefix
+	}
+}
+
+//set the account of a user we know about, empty if they are not logged in
+func (t *tracker) account(nick, account string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if user, ok := t.users[strings.ToLower(nick)]; ok {
+		user.Account = account
+	}
+}
+
+//the channels we share with the nick
+func (t *tracker) channelsOf(nick string) []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var rooms []string
+	key := strings.ToLower(nick)
+
+	for _, channel := range t.channels {
+		if _, ok := channel.members[key]; ok {
+			rooms = append(rooms, channel.name)
+		}
+	}
+	sort.Strings(rooms)
+
+	return rooms
+}
+
+//set the topic and add it to the history, unless it's the topic we already have
+func (t *tracker) topic(room string, change TopicChange) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	channel, ok := t.channels[strings.ToLower(room)]
+	if !ok {
+		return
+	}
+
+	channel.topic = change.Topic
+	if count := len(channel.topics); count > 0 && channel.topics[count-1].Topic == change.Topic {
+		return
+	}
+
+	channel.topics = append(channel.topics, change)
+	if len(channel.topics) > topicHistoryLen {
+		channel.topics = channel.topics[len(channel.topics)-topicHistoryLen:]
+	}
+}
+
+//RPL_TOPICWHOTIME follows RPL_TOPIC with who set it and when
+func (t *tracker) topicWhoTime(room, setBy, unixTime string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	channel, ok := t.channels[strings.ToLower(room)]
+	if !ok || len(channel.topics) == 0 {
+		return
+	}
+
+	latest := &channel.topics[len(channel.topics)-1]
+	latest.SetBy = setBy
+	if seconds, err := strconv.ParseInt(unixTime, 10, 64); err == nil {
+		latest.Time = time.Unix(seconds, 0)
+	}
+}
+
+func (t *tracker) changeHost(nick, ident, host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if user, ok := t.users[strings.ToLower(nick)]; ok {
+		user.Ident = ident
+		user.Host = host
+	}
+}
+
+func (t *tracker) setName(nick, realName string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if user, ok := t.users[strings.ToLower(nick)]; ok {
+		user.RealName = realName
+	}
+}
+
+//set the away state of a user we know about, an empty message means they are back
+func (t *tracker) away(nick, message string, away bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if user, ok := t.users[strings.ToLower(nick)]; ok {
+		user.Away = away
+		user.AwayMessage = message
+	}
+}
+
+//fold the WHO replies into the users we know about
+func (t *tracker) who(replies []WhoReply) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, reply := range replies {
+		user, ok := t.users[strings.ToLower(reply.Nick)]
+		if !ok {
+			continue
+		}
+
+		if len(reply.User) > 0 {
+			user.Ident = reply.User
+		}
+		if len(reply.Host) > 0 {
+			user.Host = reply.Host
+		}
+		if len(reply.RealName) > 0 {
+			user.RealName = reply.RealName
+		}
+		if len(reply.Account) > 0 {
+			user.Account = reply.Account
+		}
+		if len(reply.Flags) > 0 {
+			if user.Away != reply.Away() {
+				user.AwayMessage = ""
+			}
+			user.Away = reply.Away()
+		}
+	}
+}
+
+func (t *tracker) reset() {
+	t.mu.Lock()
+	t.users = make(map[string]*User)
+	t.channels = make(map[string]*channelState)
+	t.mu.Unlock()
+}
@@ -0,0 +1,55 @@
+package irc
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+//how many topics are kept per channel
+const topicHistoryLen = 20
+
+//TopicChange a topic a channel had, SetBy is the nick or mask of who set it
+type TopicChange struct {
+	Topic string
+	SetBy string
+	Time  time.Time
+}
+
+//SetTopic changes the topic of the channel, an empty topic clears it
+func (c *Client) SetTopic(channel, topic string) error {
+	if !c.server.isRunning() {
+		return errors.New("Not connected to a server")
+	}
+	if len(strings.TrimSpace(channel)) == 0 {
+		return errors.New("A channel is required")
+	}
+
+	return c.server.topic(channel, topic)
+}
+
+//TopicHistory the topics the channel had since we joined, oldest first
+func (c *Client) TopicHistory(channel string) []TopicChange {
+	c.state.mu.RLock()
+	defer c.state.mu.RUnlock()
+
+	state, ok := c.state.channels[strings.ToLower(channel)]
+	if !ok {
+		return nil
+	}
+
+	history := make([]TopicChange, len(state.topics))
+	copy(history, state.topics)
+
+	return history
+}
+
+//RevertTopic sets the topic of the channel back to the one it had before the current topic
+func (c *Client) RevertTopic(channel string) error {
+	history := c.TopicHistory(channel)
+	if len(history) < 2 {
+		return errors.New("No previous topic for " + channel)
+	}
+
+	return c.SetTopic(channel, history[len(history)-2].Topic)
+}
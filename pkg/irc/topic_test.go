@@ -0,0 +1,76 @@
+package irc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTopic(t *testing.T) {
+	server := newTestServer(t)
+	c := testClient(server.address())
+
+	go c.StartConnection()
+	defer c.StopConnection()
+
+	server.accept()
+	server.expect("USER")
+	server.register("me")
+
+	if err := c.RevertTopic("#go"); err == nil {
+		t.Error("reverted the topic of a channel we aren't in")
+	}
+
+	server.send(
+		":me!u@h JOIN #go",
+		":srv 332 me #go :Welcome to #go",
+		":srv 333 me #go ann!u@h 1700000000",
+		":joe!u@h TOPIC #go :spam",
+	)
+	eventually(t, "the topic change", func() bool { return len(c.TopicHistory("#go")) == 2 })
+
+	history := c.TopicHistory("#go")
+	if history[0].Topic != "Welcome to #go" || history[0].SetBy != "ann!u@h" || !history[0].Time.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("the first topic is %+v", history[0])
+	}
+	if history[1].Topic != "spam" || history[1].SetBy != "joe" {
+		t.Errorf("the second topic is %+v", history[1])
+	}
+	if channel, _ := c.Channel("#go"); channel.Topic != "spam" || channel.TopicSetBy != "joe" {
+		t.Errorf("#go has the topic %q set by %q", channel.Topic, channel.TopicSetBy)
+	}
+
+	if err := c.RevertTopic("#go"); err != nil {
+		t.Fatal(err)
+	}
+	server.expect("TOPIC #go :Welcome to #go")
+
+	if err := c.SetTopic("#go", ""); err != nil {
+		t.Fatal(err)
+	}
+	if line := server.expect("TOPIC"); line != "TOPIC #go :" {
+		t.Errorf("clearing the topic sent %q", line)
+	}
+
+	// RPL_NOTOPIC is an empty topic in the history
+	server.send(":srv 331 me #go :No topic is set")
+	eventually(t, "the cleared topic", func() bool { return len(c.TopicHistory("#go")) == 3 })
+	if channel, _ := c.Channel("#go"); len(channel.Topic) != 0 {
+		t.Errorf("#go still has the topic %q", channel.Topic)
+	}
+}
+
+func TestTopicHistoryLen(t *testing.T) {
+	c := NewClient("me", "", "unused.invalid")
+	c.state.join(IncomingData{Nick: "me", Room: "#go"}, true)
+
+	for i := 0; i < topicHistoryLen+5; i++ {
+		c.state.topic("#go", TopicChange{Topic: string(rune('a' + i))})
+	}
+	// setting the same topic again isn't a change
+	c.state.topic("#go", TopicChange{Topic: string(rune('a' + topicHistoryLen + 4))})
+
+	history := c.TopicHistory("#go")
+	if len(history) != topicHistoryLen || history[0].Topic != "f" {
+		t.Errorf("kept %d topics starting with %q, want %d starting with f", len(history), history[0].Topic, topicHistoryLen)
+	}
+}
@@ -0,0 +1,244 @@
+package irc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//the order WHOX sends the requested fields in, regardless of the order they were asked for
+const whoxFieldOrder = "tcuihsnfdlaor"
+
+//WhoReply a single entry from a WHO response. Fields that were not requested with WHOX are left empty
+type WhoReply struct {
+	Channel  string
+	User     string
+	IP       string
+	Host     string
+	Server   string
+	Nick     string
+	Flags    string
+	Hops     int
+	Idle     int
+	Account  string
+	OpLevel  string
+	RealName string
+}
+
+//Away returns true if the user is marked as away (G flag)
+func (w WhoReply) Away() bool {
+	return strings.HasPrefix(w.Flags, "G")
+}
+
+//Oper returns true if the user is an irc operator (* flag)
+func (w WhoReply) Oper() bool {
+	return strings.Contains(w.Flags, "*")
+}
+
+//Who sends a WHO query for the mask and blocks until the server ends the reply or the ctx is done.
+//fields are the WHOX fields to request, e.g. "%tcuhnfar", they are only used if the server advertises
+//WHOX, otherwise the classic RPL_WHOREPLY is parsed. This must not be called from an event callback
+func (c *Client) Who(ctx context.Context, mask, fields string) ([]WhoReply, error) {
+	if !c.server.isRunning() {
+		return nil, errors.New("Not connected to a server")
+	}
+
+	whox := c.isupport.Has("WHOX")
+	token := ""
+	fields = whoxFields(fields)
+
+	if whox {
+		c.mu.Lock()
+		c.whoToken = (c.whoToken + 1) % 1000
+		token = strconv.Itoa(c.whoToken)
+		c.mu.Unlock()
+	} else {
+		// classic replies carry nothing to tell two queries apart, so only one can run at a time
+		c.whoMu.Lock()
+		defer c.whoMu.Unlock()
+	}
+
+	// RPL_ENDOFWHO doesn't carry the token, but servers answer in order, so our query ends with the
+	// RPL_ENDOFWHO for the mask that follows the ones of the queries sent before it
+	key := strings.ToLower(mask)
+	c.mu.Lock()
+	position := c.whoSent[key]
+	c.whoSent[key]++
+	c.mu.Unlock()
+
+	var replies []WhoReply
+	result := make(chan []WhoReply, 1)
+
+	remove := c.addHook(func(line IncomingData) bool {
+		switch line.Code {
+		case RPL_WHOSPCRPL:
+			if whox && len(line.Params) > 1 && line.Params[1] == token {
+				replies = append(replies, parseWhoX(fields, line.Params[1:]))
+			}
+
+		case RPL_WHOREPLY:
+			if !whox {
+				replies = append(replies, parseWhoReply(line.Params))
+			}
+
+		case RPL_ENDOFWHO:
+			if len(line.Params) > 1 && strings.EqualFold(line.Params[1], mask) && c.whoEndCount(key) > position {
+				result <- replies
+				return true
+			}
+		}
+
+		return false
+	})
+
+	whoFields := ""
+	if whox {
+		whoFields = fmt.Sprintf("%%%s,%s", fields, token)
+	}
+	if err := c.server.who(mask, whoFields); err != nil {
+		remove()
+
+		c.mu.Lock()
+		c.whoSent[key]--
+		c.mu.Unlock()
+		return nil, err
+	}
+
+	select {
+	case replies := <-result:
+		c.state.who(replies)
+		return replies, nil
+
+	case <-ctx.Done():
+		remove()
+		return nil, ctx.Err()
+	}
+}
+
+//count the RPL_ENDOFWHO for each mask before the hooks see it, the counts are dropped once every query
+//sent for the mask has ended
+func (c *Client) countWhoEnd(line IncomingData) {
+	if line.Code != RPL_ENDOFWHO || len(line.Params) < 2 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := strings.ToLower(line.Params[1])
+	if c.whoSent[key] == 0 {
+		return
+	}
+
+	c.whoEnded[key]++
+	if c.whoEnded[key] >= c.whoSent[key] {
+		delete(c.whoSent, key)
+		delete(c.whoEnded, key)
+	}
+}
+
+//whoEndCount how many RPL_ENDOFWHO arrived for the mask, counting the one being handled
+func (c *Client) whoEndCount(key string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.whoSent[key]; !ok {
+		// every query for the mask has ended, including ours
+		return math.MaxInt
+	}
+
+	return c.whoEnded[key]
+}
+
+//make sure the token field is requested and drop anything WHOX doesn't know about
+func whoxFields(fields string) string {
+	fields = strings.TrimPrefix(fields, "%")
+	if index := strings.Index(fields, ","); index != -1 {
+		fields = fields[:index]
+	}
+
+	requested := "t"
+	for _, field := range whoxFieldOrder[1:] {
+		if strings.ContainsRune(fields, field) {
+			requested += string(field)
+		}
+	}
+
+	return requested
+}
+
+//parse a RPL_WHOSPCRPL, params start at the token and follow whoxFieldOrder for the requested fields
+func parseWhoX(fields string, params []string) WhoReply {
+	reply := WhoReply{}
+	index := 0
+
+	for _, field := range whoxFieldOrder {
+		if !strings.ContainsRune(fields, field) {
+			continue
+		}
+		if index >= len(params) {
+			break
+		}
+
+		value := params[index]
+		index++
+
+		switch field {
+		case 'c':
+			reply.Channel = value
+		case 'u':
+			reply.User = value
+		case 'i':
+			reply.IP = value
+		case 'h':
+			reply.Host = value
+		case 's':
+			reply.Server = value
+		case 'n':
+			reply.Nick = value
+		case 'f':
+			reply.Flags = value
+		case 'd':
+			reply.Hops, _ = strconv.Atoi(value)
+		case 'l':
+			reply.Idle, _ = strconv.Atoi(value)
+		case 'a':
+			// 0 means the user isn't logged in to an account
+			if value != "0" {
+				reply.Account = value
+			}
+		case 'o':
+			reply.OpLevel = value
+		case 'r':
+			reply.RealName = value
+		}
+	}
+
+	return reply
+}
+
+//parse a RPL_WHOREPLY: <me> <channel> <user> <host> <server> <nick> <flags> :<hopcount> <realname>
+func parseWhoReply(params []string) WhoReply {
+	reply := WhoReply{}
+	if len(params) < 8 {
+		return reply
+	}
+
+	reply.Channel = params[1]
+	reply.User = params[2]
+	reply.Host = params[3]
+	reply.Server = params[4]
+	reply.Nick = params[5]
+	reply.Flags = params[6]
+
+	hops := strings.SplitN(params[7], " ", 2)
+	reply.Hops, _ = strconv.Atoi(hops[0])
+	if len(hops) > 1 {
+		reply.RealName = hops[1]
+	}
+
+	return reply
+}
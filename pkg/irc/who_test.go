@@ -0,0 +1,91 @@
+package irc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWhoxFields(t *testing.T) {
+	tests := []struct {
+		fields string
+		want   string
+	}{
+		{"", "t"},
+		{"%cuhnfar", "tcuhnfar"},
+		{"%ranfhuc,123", "tcuhnfar"},
+		{"nxyz", "tn"},
+	}
+
+	for _, test := range tests {
+		if got := whoxFields(test.fields); got != test.want {
+			t.Errorf("whoxFields(%q) = %q, want %q", test.fields, got, test.want)
+		}
+	}
+}
+
+func TestParseWhoX(t *testing.T) {
+	tests := []struct {
+		line string
+		want WhoReply
+	}{
+		{
+			":srv 354 me 7 #go ~joe host.example joe H@ joeacct :Joe Example",
+			WhoReply{Channel: "#go", User: "~joe", Host: "host.example", Nick: "joe", Flags: "H@", Account: "joeacct", RealName: "Joe Example"},
+		},
+		{
+			":srv 354 me 7 #go ~ann host.example ann G 0 :Ann",
+			WhoReply{Channel: "#go", User: "~ann", Host: "host.example", Nick: "ann", Flags: "G", RealName: "Ann"},
+		},
+	}
+
+	for _, test := range tests {
+		line, ok := parseRawInput(test.line)
+		if !ok || line.Code != RPL_WHOSPCRPL {
+			t.Fatalf("%q didn't parse as RPL_WHOSPCRPL", test.line)
+		}
+
+		got := parseWhoX("tcuhnfar", line.Params[1:])
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseWhoX(%q)\n got %+v\nwant %+v", test.line, got, test.want)
+		}
+	}
+}
+
+func TestParseWhoReply(t *testing.T) {
+	line, ok := parseRawInput(":srv 352 me #go ~joe host.example irc.example joe H*@ :2 Joe Example")
+	if !ok {
+		t.Fatal("RPL_WHOREPLY didn't parse")
+	}
+
+	got := parseWhoReply(line.Params)
+	want := WhoReply{Channel: "#go", User: "~joe", Host: "host.example", Server: "irc.example", Nick: "joe", Flags: "H*@", Hops: 2, RealName: "Joe Example"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+	if !got.Oper() || got.Away() {
+		t.Errorf("flags %q: Oper %v Away %v", got.Flags, got.Oper(), got.Away())
+	}
+}
+
+func TestWhoEndOrder(t *testing.T) {
+	c := NewClient("me", "", "irc.example")
+	c.whoSent = map[string]int{"#go": 2}
+	c.whoEnded = make(map[string]int)
+
+	end, _ := parseRawInput(":srv 315 me #Go :End of WHO list")
+
+	// the first RPL_ENDOFWHO ends the first query only
+	c.countWhoEnd(end)
+	if c.whoEndCount("#go") <= 0 || c.whoEndCount("#go") > 1 {
+		t.Fatalf("after one end the count is %d, want 1", c.whoEndCount("#go"))
+	}
+
+	// the second ends the other one and the counts are dropped
+	c.countWhoEnd(end)
+	if c.whoEndCount("#go") <= 1 {
+		t.Fatalf("after both ends the second query is still waiting")
+	}
+	if len(c.whoSent) != 0 || len(c.whoEnded) != 0 {
+		t.Errorf("counts weren't dropped: %v %v", c.whoSent, c.whoEnded)
+	}
+}